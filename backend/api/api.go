@@ -1,13 +1,35 @@
 package api
 
+import "encoding/json"
+
 type CompressTradesReq struct {
-	RequestID  string `json:"request_id,omitempty"`
-	InputFiles []File `json:"input_files"`
+	RequestID     string            `json:"request_id,omitempty"`
+	InputFiles    []File            `json:"input_files"`
+	ColumnMapping map[string]string `json:"column_mapping,omitempty"`
+	AttributeRule string            `json:"attribute_rule,omitempty"`
+	// PartyDateFormats gives the date format, "ISO", "DMY" or "MDY", of the maturity dates of each party
+	PartyDateFormats map[string]string `json:"party_date_formats,omitempty"`
+	StrictDates      bool              `json:"strict_dates,omitempty"`
+	// AsOfDate excludes the trades maturing on or before it, or within MinResidualBusinessDays business days after it
+	AsOfDate                string `json:"as_of_date,omitempty"`
+	MinResidualBusinessDays int    `json:"min_residual_business_days,omitempty"`
+	// BusinessDayConvention adjusts the maturity dates to business days before pairing, "none", "following" or "modified_following"
+	BusinessDayConvention string `json:"business_day_convention,omitempty"`
+	// NotionalTolerance, RelativeNotionalTolerance and MaturityToleranceDays pair the two sides of a cleared trade
+	// whose notionals or maturity dates differ within tolerance, the trade of CanonicalSide, "pay", "receive"
+	// or a party, gives the values used for both sides
+	NotionalTolerance         json.Number `json:"notional_tolerance,omitempty"`
+	RelativeNotionalTolerance json.Number `json:"relative_notional_tolerance,omitempty"`
+	MaturityToleranceDays     int         `json:"maturity_tolerance_days,omitempty"`
+	CanonicalSide             string      `json:"canonical_side,omitempty"`
 }
 
 type File struct {
-	FileName    string `json:"file_name"`
-	FileContent string `json:"file_content"`
+	FileName             string `json:"file_name"`
+	FileContent          string `json:"file_content"`
+	SheetName            string `json:"sheet_name,omitempty"`
+	ColumnMappingProfile string `json:"column_mapping_profile,omitempty"`
+	DateFormat           string `json:"date_format,omitempty"`
 }
 
 type CompressTradesResp struct {
@@ -17,19 +39,153 @@ type CompressTradesResp struct {
 	CompressionReportBookLevel string      `json:"compression_report_book_level"`
 	Proposals                  []Proposal  `json:"proposals"`
 	DataCheck                  string      `json:"data_check"`
+	BreakReport                string      `json:"break_report"`
 	Statistics                 []Statistic `json:"statistics"`
 	Error                      string      `json:"error,omitempty"`
 }
 
 type Statistic struct {
-	Party              string `json:"party"`
-	OriginalNotional   uint64 `json:"original_notional"`
-	NewNotional        uint64 `json:"new_notional"`
-	OriginalNoOfTrades uint64 `json:"original_no_of_trades"`
-	NewNoOfTrades      uint64 `json:"new_no_of_trades"`
+	Party              string      `json:"party"`
+	OriginalNotional   json.Number `json:"original_notional"`
+	NewNotional        json.Number `json:"new_notional"`
+	OriginalNoOfTrades uint64      `json:"original_no_of_trades"`
+	NewNoOfTrades      uint64      `json:"new_no_of_trades"`
 }
 
 type Proposal struct {
 	Party    string `json:"party"`
 	Proposal string `json:"proposal"`
 }
+
+type JobStatus struct {
+	RequestID   string `json:"request_id"`
+	Stage       string `json:"stage,omitempty"`
+	SubmittedAt string `json:"submitted_at,omitempty"`
+	FinishedAt  string `json:"finished_at,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type RunSummary struct {
+	RequestID  string      `json:"request_id"`
+	CreatedAt  string      `json:"created_at"`
+	InputFiles []string    `json:"input_files"`
+	InputHash  string      `json:"input_hash"`
+	Statistics []Statistic `json:"statistics,omitempty"`
+}
+
+type CompressTradesResult struct {
+	RequestID                  string                       `json:"request_id"`
+	Exclusion                  []ExcludedTrade              `json:"exclusion"`
+	CompressionReport          []CompressionResult          `json:"compression_report"`
+	CompressionReportBookLevel []CompressionResultBookLevel `json:"compression_report_book_level"`
+	Proposals                  []PartyProposals             `json:"proposals"`
+	DataCheck                  []DataCheckResult            `json:"data_check"`
+	BreakReport                []BreakCandidate             `json:"break_report"`
+	Statistics                 []Statistic                  `json:"statistics"`
+	Error                      string                       `json:"error,omitempty"`
+}
+
+type ExcludedTrade struct {
+	Party                string      `json:"party"`
+	Book                 string      `json:"book"`
+	TradeID              string      `json:"trade_id"`
+	PayOrReceive         string      `json:"pay_or_receive"`
+	Currency             string      `json:"currency"`
+	MaturityDate         string      `json:"maturity_date"`
+	AdjustedMaturityDate string      `json:"adjusted_maturity_date,omitempty"`
+	Cpty                 string      `json:"cpty"`
+	CCPTradeID           string      `json:"ccp_trade_id"`
+	Notional             json.Number `json:"notional,omitempty"`
+	RawNotional          string      `json:"raw_notional,omitempty"`
+	Error                string      `json:"error"`
+	Warning              string      `json:"warning,omitempty"`
+	FileName             string      `json:"file_name,omitempty"`
+	RowNumber            int         `json:"row_number,omitempty"`
+}
+
+type BreakCandidate struct {
+	Party               string  `json:"party"`
+	Book                string  `json:"book"`
+	TradeID             string  `json:"trade_id"`
+	CCPTradeID          string  `json:"ccp_trade_id"`
+	FileName            string  `json:"file_name,omitempty"`
+	RowNumber           int     `json:"row_number,omitempty"`
+	Rank                int     `json:"rank,omitempty"`
+	Similarity          float64 `json:"similarity,omitempty"`
+	CandidateParty      string  `json:"candidate_party,omitempty"`
+	CandidateBook       string  `json:"candidate_book,omitempty"`
+	CandidateTradeID    string  `json:"candidate_trade_id,omitempty"`
+	CandidateCCPTradeID string  `json:"candidate_ccp_trade_id,omitempty"`
+	CandidateFileName   string  `json:"candidate_file_name,omitempty"`
+	CandidateRowNumber  int     `json:"candidate_row_number,omitempty"`
+	Differences         string  `json:"differences"`
+}
+
+type CompressionResult struct {
+	Party                string      `json:"party"`
+	Currency             string      `json:"currency"`
+	MaturityDate         string      `json:"maturity_date"`
+	AdjustedMaturityDate string      `json:"adjusted_maturity_date"`
+	PayOrReceive         string      `json:"pay_or_receive"`
+	CompressionType      string      `json:"compression_type"`
+	OriginalNotional     json.Number `json:"original_notional"`
+	Notional             json.Number `json:"notional"`
+	CompressionRate      float64     `json:"compression_rate"`
+	Warning              string      `json:"warning,omitempty"`
+}
+
+type CompressionResultBookLevel struct {
+	Party                string      `json:"party"`
+	Book                 string      `json:"book"`
+	Currency             string      `json:"currency"`
+	MaturityDate         string      `json:"maturity_date"`
+	AdjustedMaturityDate string      `json:"adjusted_maturity_date"`
+	PayOrReceive         string      `json:"pay_or_receive"`
+	CompressionType      string      `json:"compression_type"`
+	OriginalNotional     json.Number `json:"original_notional"`
+	Notional             json.Number `json:"notional"`
+	CompressionRate      float64     `json:"compression_rate"`
+	Warning              string      `json:"warning,omitempty"`
+}
+
+type PartyProposals struct {
+	Party     string        `json:"party"`
+	Proposals []ProposalRow `json:"proposals"`
+}
+
+type ProposalRow struct {
+	Party                string            `json:"party"`
+	Book                 string            `json:"book"`
+	TradeID              string            `json:"trade_id"`
+	PayOrReceive         string            `json:"pay_or_receive"`
+	Currency             string            `json:"currency"`
+	MaturityDate         string            `json:"maturity_date"`
+	AdjustedMaturityDate string            `json:"adjusted_maturity_date"`
+	Cpty                 string            `json:"cpty"`
+	CCPTradeID           string            `json:"ccp_trade_id"`
+	Notional             json.Number       `json:"notional"`
+	Action               string            `json:"action"`
+	Warning              string            `json:"warning,omitempty"`
+	Attributes           map[string]string `json:"attributes,omitempty"`
+}
+
+type DataCheckResult struct {
+	Party            string      `json:"party"`
+	TotalIn          json.Number `json:"total_in"`
+	TotalOut         json.Number `json:"total_out"`
+	NetOut           json.Number `json:"net_out"`
+	OriginalNotional json.Number `json:"original_notional"`
+	Notional         json.Number `json:"notional"`
+	Reduced          bool        `json:"reduced"`
+}
+
+type BundleManifest struct {
+	RequestID string       `json:"request_id"`
+	Files     []BundleFile `json:"files"`
+}
+
+type BundleFile struct {
+	FileName string `json:"file_name"`
+	Rows     int    `json:"rows"`
+	SHA256   string `json:"sha256"`
+}
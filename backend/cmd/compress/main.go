@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"github.com/zytan787/code-to-connect-2021/internal"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	outputDir := flag.String("out", "output", "directory to write the reports and proposals into")
+	attributeRule := flag.String("attribute-rule", string(internal.DEFAULT_ATTRIBUTE_RULE),
+		"how added trades get the extra columns of the input: largest_cancelled, common or none")
+	dateFormat := flag.String("date-format", "", "order of day and month in the maturity dates of every file: ISO, DMY or MDY")
+	currencies := flag.String("currencies", "", "comma separated currencies allowed in the trades, every ISO 4217 currency when empty")
+	asOfDate := flag.String("as-of", "", "as-of date like 2025/04/03, trades maturing on or before it are excluded")
+	minBusinessDays := flag.Int("min-business-days", 0, "exclude trades maturing within this many business days after the as-of date")
+	calendarsDir := flag.String("calendars", "", "directory with one holiday calendar file per currency, like USD.csv")
+	convention := flag.String("business-day-convention", string(internal.BUSINESS_DAY_CONVENTION_NONE),
+		"how maturity dates are adjusted to business days before pairing: none, following or modified_following")
+	notionalTolerance := flag.String("notional-tolerance", "", "largest difference between the notionals of paired trades, like 0.05")
+	relativeNotionalTolerance := flag.String("relative-notional-tolerance", "",
+		"largest difference between the notionals of paired trades as a fraction of the larger notional, like 0.0001")
+	maturityToleranceDays := flag.Int("maturity-tolerance-days", 0, "largest number of days between the maturity dates of paired trades")
+	canonicalSide := flag.String("canonical-side", internal.CANONICAL_SIDE_PAY,
+		"side whose values are used for paired trades that differ within tolerance: pay, receive or a party")
+	strictDates := flag.Bool("strict-dates", false, "exclude trades with ambiguous maturity dates or dates not matching the date format")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] file [file ...]\nEach file can be CSV, XLSX or FpML.\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	rule, err := internal.ParseAttributeRule(*attributeRule)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	format, err := internal.ParseDateFormat(*dateFormat)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	allowedCurrencies, err := internal.ParseAllowedCurrencies(*currencies)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	tenorOptions, err := internal.ParseTenorOptions(*asOfDate, *minBusinessDays)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	businessDayConvention, err := internal.ParseBusinessDayConvention(*convention)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	pairingOptions, err := internal.ParsePairingOptions(*notionalTolerance, *relativeNotionalTolerance,
+		*maturityToleranceDays, *canonicalSide)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var holidayCalendars internal.HolidayCalendars
+	if len(*calendarsDir) > 0 {
+		holidayCalendars, err = internal.LoadHolidayCalendars(*calendarsDir)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	handler := internal.NewMainHandler()
+	handler.AttributeRule = rule
+	handler.DateOptions.Strict = *strictDates
+	handler.AllowedCurrencies = allowedCurrencies
+	handler.TenorOptions = tenorOptions
+	handler.HolidayCalendars = holidayCalendars
+	handler.BusinessDayConvention = businessDayConvention
+	handler.PairingOptions = pairingOptions
+
+	err = compress(handler, flag.Args(), *outputDir, format)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func compress(handler *internal.MainHandler, filePaths []string, outputDir string, dateFormat internal.DateFormat) error {
+	err := loadInputFiles(handler, filePaths, dateFormat)
+	if err != nil {
+		return err
+	}
+	log.Printf("Loaded %d files\n", len(filePaths))
+
+	if err = handler.GenerateCompressionResults(context.Background()); err != nil {
+		return fmt.Errorf("Error in GenerateCompressionResults due to: %s", err.Error())
+	}
+	if err = handler.GenerateProposals(); err != nil {
+		return fmt.Errorf("Error in GenerateProposals due to: %s", err.Error())
+	}
+	if err = handler.CheckData(); err != nil {
+		return fmt.Errorf("Error in CheckData due to: %s", err.Error())
+	}
+	if err = handler.GenerateBookLevelCompressionResults(context.Background()); err != nil {
+		return fmt.Errorf("Error in GenerateBookLevelCompressionResults due to: %s", err.Error())
+	}
+
+	if err = os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+
+	exclusion, err := handler.GetExcludedTradesAsCSV()
+	if err != nil {
+		return fmt.Errorf("Error in GetExcludedTradesAsCSV due to: %s", err.Error())
+	}
+	if err = writeBase64File(outputDir, internal.EXCLUSION_FILE, exclusion); err != nil {
+		return err
+	}
+
+	compressionReport, err := handler.GetCompressionReportAsCSV()
+	if err != nil {
+		return fmt.Errorf("Error in GetCompressionReportAsCSV due to: %s", err.Error())
+	}
+	if err = writeBase64File(outputDir, internal.COMPRESSION_REPORT_FILE, compressionReport); err != nil {
+		return err
+	}
+
+	compressionReportBookLevel, err := handler.GetCompressionReportBookLevelAsCSV()
+	if err != nil {
+		return fmt.Errorf("Error in GetCompressionReportBookLevelAsCSV due to: %s", err.Error())
+	}
+	if err = writeBase64File(outputDir, internal.COMPRESSION_REPORT_BOOK_LEVEL_FILE, compressionReportBookLevel); err != nil {
+		return err
+	}
+
+	proposals, err := handler.GetProposalsAsCSV()
+	if err != nil {
+		return fmt.Errorf("Error in GetProposalsAsCSV due to: %s", err.Error())
+	}
+	for _, proposal := range proposals {
+		if err = writeBase64File(outputDir, internal.GetProposalsFileName(proposal.Party), proposal.Proposal); err != nil {
+			return err
+		}
+	}
+
+	dataCheck, err := handler.GetDataCheckResultsAsCSV()
+	if err != nil {
+		return fmt.Errorf("Error in GetDataCheckResultsAsCSV due to: %s", err.Error())
+	}
+	if err = writeBase64File(outputDir, internal.DATA_CHECK_FILE, dataCheck); err != nil {
+		return err
+	}
+
+	breakReport, err := handler.GetBreakReportAsCSV()
+	if err != nil {
+		return fmt.Errorf("Error in GetBreakReportAsCSV due to: %s", err.Error())
+	}
+	if err = writeBase64File(outputDir, internal.BREAK_REPORT_FILE, breakReport); err != nil {
+		return err
+	}
+
+	log.Printf("Wrote reports for %d parties with %d excluded trades into %s\n",
+		len(proposals), len(handler.PortfolioLoader.ExcludedTrades), outputDir)
+	return nil
+}
+
+// loadInputFiles reads every file straight from disk into the portfolio loader, one trade at a time
+func loadInputFiles(handler *internal.MainHandler, filePaths []string, dateFormat internal.DateFormat) error {
+	handler.StartLoadingPortfolio()
+	for _, filePath := range filePaths {
+		if err := loadInputFile(handler, filePath, dateFormat); err != nil {
+			return err
+		}
+	}
+	handler.FinishLoadingPortfolio()
+	return nil
+}
+
+func loadInputFile(handler *internal.MainHandler, filePath string, dateFormat internal.DateFormat) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("unable to read file %s due to: %s", filePath, err.Error())
+	}
+	defer file.Close()
+
+	return handler.LoadInputFile(filepath.Base(filePath), internal.InputFileOptions{DateFormat: dateFormat}, file)
+}
+
+func writeBase64File(outputDir string, fileName string, content string) error {
+	contentBytes, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(outputDir, fileName), contentBytes, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write file %s due to: %s", fileName, err.Error())
+	}
+	return nil
+}
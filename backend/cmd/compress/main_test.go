@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/zytan787/code-to-connect-2021/internal"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := filepath.Join(t.TempDir(), "output")
+
+	files := map[string]string{
+		"a.csv": "Party,Book,TradeID,PAY/RECEIVE,Currency,MaturityDate,Cpty,CCPTradeID,Notional\n" +
+			"A,1BKA,A0,R,EUR,31/12/2022,D,CCP0,467749\n" +
+			"A,1BKA,A1,P,EUR,31/12/2022,D,CCP1,100000\n",
+		"d.csv": "Party,Book,TradeID,PAY/RECEIVE,Currency,MaturityDate,Cpty,CCPTradeID,Notional\n" +
+			"D,1BKD,D0,P,EUR,2022/12/31,A,CCP0,467749\n" +
+			"D,1BKD,D1,R,EUR,2022/12/31,A,CCP1,100000\n" +
+			"D,1BKD,D2,R,EUR,2022/12/31,A,CCP2,abc\n",
+	}
+	filePaths := make([]string, 0, len(files))
+	for fileName, content := range files {
+		filePath := filepath.Join(inputDir, fileName)
+		if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		filePaths = append(filePaths, filePath)
+	}
+
+	if err := compress(internal.NewMainHandler(), filePaths, outputDir, internal.DATE_FORMAT_DMY); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fileName := range []string{internal.EXCLUSION_FILE, internal.COMPRESSION_REPORT_FILE,
+		internal.COMPRESSION_REPORT_BOOK_LEVEL_FILE, internal.DATA_CHECK_FILE, internal.BREAK_REPORT_FILE,
+		internal.GetProposalsFileName("A"), internal.GetProposalsFileName("D")} {
+		content, err := ioutil.ReadFile(filepath.Join(outputDir, fileName))
+		if err != nil {
+			t.Errorf("expected %s to be written, got %s", fileName, err.Error())
+			continue
+		}
+		if fileName == internal.EXCLUSION_FILE && !strings.Contains(string(content), "D2") {
+			t.Errorf("expected D2 to be excluded, got %s", content)
+		}
+		if fileName == internal.GetProposalsFileName("A") && !strings.Contains(string(content), "367749") {
+			t.Errorf("expected A to get a trade of 367749, got %s", content)
+		}
+	}
+}
+
+func TestCompressWithMissingFile(t *testing.T) {
+	err := compress(internal.NewMainHandler(), []string{filepath.Join(t.TempDir(), "missing.csv")}, t.TempDir(),
+		internal.DATE_FORMAT_AUTO)
+	if err == nil || !strings.Contains(err.Error(), "missing.csv") {
+		t.Errorf("expected an error naming missing.csv, got %v", err)
+	}
+}
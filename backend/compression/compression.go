@@ -0,0 +1,229 @@
+// Package compression runs the trade compression pipeline without the HTTP server.
+package compression
+
+import (
+	"context"
+	"fmt"
+	"github.com/zytan787/code-to-connect-2021/api"
+	"github.com/zytan787/code-to-connect-2021/internal"
+	"github.com/zytan787/code-to-connect-2021/internal/toolkit"
+)
+
+type AttributeRule string
+type DateFormat string
+type BusinessDayConvention string
+
+const (
+	ATTRIBUTE_RULE_LARGEST_CANCELLED AttributeRule = AttributeRule(internal.ATTRIBUTE_RULE_LARGEST_CANCELLED)
+	ATTRIBUTE_RULE_COMMON            AttributeRule = AttributeRule(internal.ATTRIBUTE_RULE_COMMON)
+	ATTRIBUTE_RULE_NONE              AttributeRule = AttributeRule(internal.ATTRIBUTE_RULE_NONE)
+
+	DATE_FORMAT_AUTO DateFormat = DateFormat(internal.DATE_FORMAT_AUTO)
+	DATE_FORMAT_ISO  DateFormat = DateFormat(internal.DATE_FORMAT_ISO)
+	DATE_FORMAT_DMY  DateFormat = DateFormat(internal.DATE_FORMAT_DMY)
+	DATE_FORMAT_MDY  DateFormat = DateFormat(internal.DATE_FORMAT_MDY)
+
+	BUSINESS_DAY_CONVENTION_NONE               BusinessDayConvention = BusinessDayConvention(internal.BUSINESS_DAY_CONVENTION_NONE)
+	BUSINESS_DAY_CONVENTION_FOLLOWING          BusinessDayConvention = BusinessDayConvention(internal.BUSINESS_DAY_CONVENTION_FOLLOWING)
+	BUSINESS_DAY_CONVENTION_MODIFIED_FOLLOWING BusinessDayConvention = BusinessDayConvention(internal.BUSINESS_DAY_CONVENTION_MODIFIED_FOLLOWING)
+
+	CANONICAL_SIDE_PAY     = internal.CANONICAL_SIDE_PAY
+	CANONICAL_SIDE_RECEIVE = internal.CANONICAL_SIDE_RECEIVE
+)
+
+// Trade is a trade as it is written in an input file, its values are checked by Compress.
+type Trade struct {
+	Party        string
+	Book         string
+	TradeID      string
+	PayOrReceive string
+	Currency     string
+	MaturityDate string
+	Cpty         string
+	CCPTradeID   string
+	// Notional is a decimal like 1000000 or 1,000,000.50.
+	Notional string
+	// FileName, SheetName and RowNumber are the source of the trade in the exclusion and break report.
+	FileName  string
+	SheetName string
+	RowNumber int
+	// DateFormat is the date format of the file of the trade, the date format of its party is used when it is empty.
+	DateFormat DateFormat
+	// Attributes holds the extra columns of the trade, like desk or trader, by header.
+	Attributes map[string]string
+}
+
+// HolidayCalendars holds the holidays of each currency, see LoadHolidayCalendars.
+type HolidayCalendars struct {
+	calendars internal.HolidayCalendars
+}
+
+// LoadHolidayCalendars reads one holiday calendar file per currency from a directory, like USD.csv.
+func LoadHolidayCalendars(dir string) (HolidayCalendars, error) {
+	calendars, err := internal.LoadHolidayCalendars(dir)
+	if err != nil {
+		return HolidayCalendars{}, err
+	}
+	return HolidayCalendars{calendars: calendars}, nil
+}
+
+type Options struct {
+	// RequestID identifies the run in the logs, a unique ID is generated when it is empty.
+	RequestID string
+	// AttributeRule decides the attributes of the added trades, ATTRIBUTE_RULE_LARGEST_CANCELLED when it is empty.
+	AttributeRule AttributeRule
+	// PartyDateFormats gives the date format of each party, Trade.DateFormat takes precedence over it.
+	PartyDateFormats map[string]DateFormat
+	// StrictDates excludes the trades with ambiguous maturity dates or dates not matching their date format.
+	StrictDates bool
+	// AllowedCurrencies limits the currencies of the trades, every ISO 4217 currency is allowed when it is empty.
+	AllowedCurrencies map[string]bool
+	// AsOfDate like 2025/04/03 excludes the trades that have matured, or mature within MinResidualBusinessDays
+	// business days after it. Nothing is excluded when it is empty.
+	AsOfDate                string
+	MinResidualBusinessDays int
+	// HolidayCalendars holds the holidays of each currency, weekends are never business days.
+	HolidayCalendars HolidayCalendars
+	// BusinessDayConvention adjusts the maturity dates to business days before pairing, BUSINESS_DAY_CONVENTION_NONE when it is empty.
+	BusinessDayConvention BusinessDayConvention
+	// NotionalTolerance like "0.05", RelativeNotionalTolerance like "0.0001" and MaturityToleranceDays accept paired
+	// trades whose notionals or maturity dates differ within tolerance, the values of the CanonicalSide are used for
+	// both trades. CanonicalSide is CANONICAL_SIDE_PAY, CANONICAL_SIDE_RECEIVE or a party, CANONICAL_SIDE_PAY when
+	// it is empty. Only exact matches are paired when the tolerances are empty.
+	NotionalTolerance         string
+	RelativeNotionalTolerance string
+	MaturityToleranceDays     int
+	CanonicalSide             string
+}
+
+// Result holds the rows of a run in the same types as the JSON of /compress_trades?format=json, notionals are
+// exact decimals. The last row of DataCheckResults is the total of every party.
+type Result struct {
+	RequestID                   string
+	ExcludedTrades              []api.ExcludedTrade
+	CompressionResults          []api.CompressionResult
+	BookLevelCompressionResults []api.CompressionResultBookLevel
+	Proposals                   []api.PartyProposals
+	DataCheckResults            []api.DataCheckResult
+	BreakReport                 []api.BreakCandidate
+	Statistics                  []api.Statistic
+}
+
+// Compress validates and pairs the trades, compresses them and generates the proposals for every party.
+// Trades that cannot be compressed are returned in Result.ExcludedTrades rather than as an error, invalid options
+// are returned as an error. The loading and compression of the trades stop once ctx is cancelled.
+func Compress(ctx context.Context, trades []*Trade, options Options) (*Result, error) {
+	if len(options.RequestID) <= 0 {
+		options.RequestID = toolkit.UniqueID()
+	}
+
+	handler, err := newMainHandler(options)
+	if err != nil {
+		return nil, err
+	}
+
+	rawTrades := make([]*internal.RawTrade, len(trades))
+	for i, trade := range trades {
+		rawTrades[i] = newRawTrade(trade)
+	}
+
+	stages := []struct {
+		name string
+		run  func() error
+	}{
+		{"LoadPortfolio", func() error { return handler.LoadPortfolio(ctx, rawTrades) }},
+		{"GenerateCompressionResults", func() error { return handler.GenerateCompressionResults(ctx) }},
+		{"GenerateProposals", handler.GenerateProposals},
+		{"CheckData", handler.CheckData},
+		{"GenerateBookLevelCompressionResults", func() error { return handler.GenerateBookLevelCompressionResults(ctx) }},
+	}
+
+	for _, stage := range stages {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err = stage.run(); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, fmt.Errorf("Error in %s due to: %s", stage.name, err.Error())
+		}
+	}
+
+	apiResult, err := handler.GetRunResult(options.RequestID).ToAPIResult()
+	if err != nil {
+		return nil, fmt.Errorf("Error in ToAPIResult due to: %s", err.Error())
+	}
+	return &Result{
+		RequestID:                   apiResult.RequestID,
+		ExcludedTrades:              apiResult.Exclusion,
+		CompressionResults:          apiResult.CompressionReport,
+		BookLevelCompressionResults: apiResult.CompressionReportBookLevel,
+		Proposals:                   apiResult.Proposals,
+		DataCheckResults:            apiResult.DataCheck,
+		BreakReport:                 apiResult.BreakReport,
+		Statistics:                  apiResult.Statistics,
+	}, nil
+}
+
+func newMainHandler(options Options) (*internal.MainHandler, error) {
+	handler := internal.NewMainHandler()
+
+	attributeRule, err := internal.ParseAttributeRule(string(options.AttributeRule))
+	if err != nil {
+		return nil, err
+	}
+	handler.AttributeRule = attributeRule
+
+	handler.DateOptions.Strict = options.StrictDates
+	if len(options.PartyDateFormats) > 0 {
+		handler.DateOptions.PartyDateFormats = make(map[string]internal.DateFormat, len(options.PartyDateFormats))
+		for party, format := range options.PartyDateFormats {
+			dateFormat, err := internal.ParseDateFormat(string(format))
+			if err != nil {
+				return nil, fmt.Errorf("date format of party %s is invalid due to: %s", party, err.Error())
+			}
+			handler.DateOptions.PartyDateFormats[party] = dateFormat
+		}
+	}
+
+	handler.AllowedCurrencies = options.AllowedCurrencies
+
+	handler.TenorOptions, err = internal.ParseTenorOptions(options.AsOfDate, options.MinResidualBusinessDays)
+	if err != nil {
+		return nil, err
+	}
+
+	handler.HolidayCalendars = options.HolidayCalendars.calendars
+	handler.BusinessDayConvention, err = internal.ParseBusinessDayConvention(string(options.BusinessDayConvention))
+	if err != nil {
+		return nil, err
+	}
+
+	handler.PairingOptions, err = internal.ParsePairingOptions(options.NotionalTolerance, options.RelativeNotionalTolerance,
+		options.MaturityToleranceDays, options.CanonicalSide)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler, nil
+}
+
+func newRawTrade(trade *Trade) *internal.RawTrade {
+	return &internal.RawTrade{
+		Party:        trade.Party,
+		Book:         trade.Book,
+		TradeID:      trade.TradeID,
+		PayOrReceive: trade.PayOrReceive,
+		Currency:     trade.Currency,
+		MaturityDate: trade.MaturityDate,
+		Cpty:         trade.Cpty,
+		CCPTradeID:   trade.CCPTradeID,
+		Notional:     trade.Notional,
+		FileName:     trade.FileName,
+		SheetName:    trade.SheetName,
+		RowNumber:    trade.RowNumber,
+		DateFormat:   internal.DateFormat(trade.DateFormat),
+		Attributes:   trade.Attributes,
+	}
+}
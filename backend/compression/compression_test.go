@@ -0,0 +1,85 @@
+package compression
+
+import (
+	"context"
+	"testing"
+)
+
+func newTestTrades() []*Trade {
+	return []*Trade{
+		{Party: "A", Book: "1BKA", TradeID: "A0", PayOrReceive: "R", Currency: "EUR", MaturityDate: "2022/12/31",
+			Cpty: "D", CCPTradeID: "CCP0", Notional: "467,749.50", FileName: "a.csv", RowNumber: 2,
+			Attributes: map[string]string{"Desk": "Rates"}},
+		{Party: "D", Book: "1BKD", TradeID: "D0", PayOrReceive: "P", Currency: "EUR", MaturityDate: "2022/12/31",
+			Cpty: "A", CCPTradeID: "CCP0", Notional: "467749.50", FileName: "d.csv", RowNumber: 2},
+		{Party: "A", Book: "1BKA", TradeID: "A1", PayOrReceive: "P", Currency: "EUR", MaturityDate: "2022/12/31",
+			Cpty: "D", CCPTradeID: "CCP1", Notional: "100000", FileName: "a.csv", RowNumber: 3},
+		{Party: "D", Book: "1BKD", TradeID: "D1", PayOrReceive: "R", Currency: "EUR", MaturityDate: "2022/12/31",
+			Cpty: "A", CCPTradeID: "CCP1", Notional: "100000", FileName: "d.csv", RowNumber: 3},
+		{Party: "A", Book: "1BKA", TradeID: "A2", PayOrReceive: "P", Currency: "EUR", MaturityDate: "31/12/2022",
+			Cpty: "D", CCPTradeID: "CCP2", Notional: "abc", FileName: "a.csv", RowNumber: 4},
+	}
+}
+
+func TestCompress(t *testing.T) {
+	result, err := Compress(context.Background(), newTestTrades(), Options{RequestID: "run"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.RequestID != "run" {
+		t.Errorf("expected request run, got %s", result.RequestID)
+	}
+	if len(result.ExcludedTrades) != 1 || result.ExcludedTrades[0].TradeID != "A2" ||
+		result.ExcludedTrades[0].FileName != "a.csv" || result.ExcludedTrades[0].RowNumber != 4 {
+		t.Errorf("expected A2 to be excluded at a.csv row 4, got %+v", result.ExcludedTrades)
+	}
+
+	if len(result.Proposals) != 2 || result.Proposals[0].Party != "A" || result.Proposals[1].Party != "D" {
+		t.Fatalf("expected the proposals of A and D, got %+v", result.Proposals)
+	}
+	var added []string
+	for _, proposal := range result.Proposals[0].Proposals {
+		if proposal.Action == "ADD" {
+			added = append(added, proposal.PayOrReceive+" "+proposal.Notional.String()+" "+proposal.Attributes["Desk"])
+		}
+	}
+	if len(added) != 1 || added[0] != "R 367749.50 Rates" {
+		t.Errorf("expected A to receive 367749.50 on desk Rates, got %v", added)
+	}
+
+	if len(result.DataCheckResults) == 0 {
+		t.Errorf("expected data check rows")
+	}
+}
+
+func TestCompressWithInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{"attribute rule", Options{AttributeRule: "largest"}},
+		{"party date format", Options{PartyDateFormats: map[string]DateFormat{"A": "YMD"}}},
+		{"as-of date", Options{AsOfDate: "31/12/2022"}},
+		{"business day convention", Options{BusinessDayConvention: "preceding"}},
+		{"notional tolerance", Options{NotionalTolerance: "-1"}},
+		{"maturity tolerance", Options{MaturityToleranceDays: -1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Compress(context.Background(), newTestTrades(), test.options); err == nil {
+				t.Errorf("expected an error for options %+v", test.options)
+			}
+		})
+	}
+}
+
+func TestCompressWithCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := Compress(ctx, newTestTrades(), Options{}); err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
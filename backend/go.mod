@@ -3,12 +3,13 @@ module github.com/zytan787/code-to-connect-2021
 go 1.16
 
 require (
-	github.com/araddon/dateparse v0.0.0-20210429162001-6b43995a97de // indirect
-	github.com/bwmarrin/snowflake v0.3.0 // indirect
-	github.com/gin-contrib/cors v1.3.1 // indirect
+	github.com/araddon/dateparse v0.0.0-20210429162001-6b43995a97de
+	github.com/bwmarrin/snowflake v0.3.0
+	github.com/gin-contrib/cors v1.3.1
 	github.com/gin-gonic/gin v1.7.2
-	github.com/gocarina/gocsv v0.0.0-20210516172204-ca9e8a8ddea8 // indirect
-	github.com/joho/godotenv v1.3.0 // indirect
-	github.com/sirupsen/logrus v1.8.1 // indirect
+	github.com/gocarina/gocsv v0.0.0-20210516172204-ca9e8a8ddea8
+	github.com/joho/godotenv v1.3.0
+	github.com/sirupsen/logrus v1.8.1
+	github.com/xuri/excelize/v2 v2.5.0
 	golang.org/x/text v0.3.6 // indirect
 )
@@ -0,0 +1,307 @@
+package internal
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/gocarina/gocsv"
+	"github.com/zytan787/code-to-connect-2021/internal/toolkit"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BREAK_CANDIDATES_LIMIT is the number of candidates listed for each trade in the break report
+const BREAK_CANDIDATES_LIMIT = 3
+
+// BREAK_MIN_SIMILARITY is the similarity below which a trade is not listed as a candidate
+const BREAK_MIN_SIMILARITY = 0.5
+
+// the weights of the fields in the similarity of two trades, they add up to 1
+const (
+	CCP_TRADE_ID_WEIGHT   = 0.3
+	PARTIES_WEIGHT        = 0.2
+	PAY_OR_RECEIVE_WEIGHT = 0.05
+	CURRENCY_WEIGHT       = 0.15
+	MATURITY_DATE_WEIGHT  = 0.15
+	NOTIONAL_WEIGHT       = 0.15
+)
+
+// MATURITY_DATE_SIMILARITY_DAYS is the number of days between maturity dates at which they are no longer similar
+const MATURITY_DATE_SIMILARITY_DAYS = 30
+
+// NOTIONAL_SIMILARITY_RATIO is the difference, as a fraction of the larger notional, at which notionals are no longer similar
+const NOTIONAL_SIMILARITY_RATIO = 0.1
+
+type breakCandidate struct {
+	trade       *Trade
+	similarity  float64
+	differences []string
+}
+
+// breakCandidateIndex holds the clean trades by the keys a likely counterpart shares with a trade, so that a trade is
+// only scored against the trades between the same two parties, with the same or a nearly equal CCPTradeID, or with
+// the same currency, maturity date and notional, instead of against every trade
+type breakCandidateIndex struct {
+	partiesToTrades           map[[2]string][]*Trade
+	ccpTradeIDVariantToTrades map[string][]*Trade
+	termsToTrades             map[[3]string][]*Trade
+}
+
+// generateBreakReport lists, for every trade that could not be paired, the trades of other parties most likely
+// to be its counterpart, ranked by their similarity to the mirror image of the trade
+func generateBreakReport(breakTrades []*Trade, cleanTrades []*Trade) []*BreakCandidate {
+	sort.Slice(breakTrades, func(i, j int) bool {
+		return compareTrades(breakTrades[i], breakTrades[j])
+	})
+	sort.Slice(cleanTrades, func(i, j int) bool {
+		return compareTrades(cleanTrades[i], cleanTrades[j])
+	})
+
+	index := newBreakCandidateIndex(cleanTrades)
+	report := make([]*BreakCandidate, 0, len(breakTrades))
+	for _, breakTrade := range breakTrades {
+		candidates := findBreakCandidates(breakTrade, index.getTrades(breakTrade))
+		if len(candidates) == 0 {
+			row := newBreakCandidate(breakTrade)
+			row.Differences = "no similar trade found"
+			report = append(report, row)
+			continue
+		}
+
+		for i, candidate := range candidates {
+			row := newBreakCandidate(breakTrade)
+			row.Rank = strconv.Itoa(i + 1)
+			row.Similarity = fmt.Sprintf("%.2f%%", candidate.similarity*100)
+			row.CandidateParty = candidate.trade.Party
+			row.CandidateBook = candidate.trade.Book
+			row.CandidateTradeID = candidate.trade.TradeID
+			row.CandidateCCPTradeID = candidate.trade.CCPTradeID
+			row.CandidateFileName = candidate.trade.FileName
+			row.CandidateRowNumber = formatRowNumber(candidate.trade.RowNumber)
+			row.Differences = strings.Join(candidate.differences, "; ")
+			report = append(report, row)
+		}
+	}
+	return report
+}
+
+func newBreakCandidateIndex(cleanTrades []*Trade) *breakCandidateIndex {
+	index := &breakCandidateIndex{
+		partiesToTrades:           make(map[[2]string][]*Trade),
+		ccpTradeIDVariantToTrades: make(map[string][]*Trade),
+		termsToTrades:             make(map[[3]string][]*Trade),
+	}
+	for _, cleanTrade := range cleanTrades {
+		parties := [2]string{cleanTrade.Party, cleanTrade.Cpty}
+		index.partiesToTrades[parties] = append(index.partiesToTrades[parties], cleanTrade)
+		for _, variant := range getCCPTradeIDVariants(cleanTrade.CCPTradeID) {
+			index.ccpTradeIDVariantToTrades[variant] = append(index.ccpTradeIDVariantToTrades[variant], cleanTrade)
+		}
+		terms := getBreakTerms(cleanTrade)
+		index.termsToTrades[terms] = append(index.termsToTrades[terms], cleanTrade)
+	}
+	return index
+}
+
+// getTrades returns the trades that may be the counterpart of the trade, in the order of compareTrades
+func (index *breakCandidateIndex) getTrades(trade *Trade) []*Trade {
+	seenTrades := make(map[*Trade]bool)
+	trades := make([]*Trade, 0)
+	addTrades := func(otherTrades []*Trade) {
+		for _, otherTrade := range otherTrades {
+			if !seenTrades[otherTrade] {
+				seenTrades[otherTrade] = true
+				trades = append(trades, otherTrade)
+			}
+		}
+	}
+
+	addTrades(index.partiesToTrades[[2]string{trade.Cpty, trade.Party}])
+	for _, variant := range getCCPTradeIDVariants(trade.CCPTradeID) {
+		addTrades(index.ccpTradeIDVariantToTrades[variant])
+	}
+	addTrades(index.termsToTrades[getBreakTerms(trade)])
+
+	sort.Slice(trades, func(i, j int) bool {
+		return compareTrades(trades[i], trades[j])
+	})
+	return trades
+}
+
+// getCCPTradeIDVariants returns the CCPTradeID and every string left by deleting one of its characters. Two IDs
+// share a variant when one character was substituted, inserted, deleted or swapped with its neighbour, so a typo
+// in a CCPTradeID is found with len(CCPTradeID)+1 lookups instead of comparing it with every other ID.
+func getCCPTradeIDVariants(ccpTradeID string) []string {
+	if len(ccpTradeID) == 0 {
+		return nil
+	}
+
+	runes := []rune(ccpTradeID)
+	variants := []string{ccpTradeID}
+	seenVariants := map[string]bool{ccpTradeID: true}
+	for i := range runes {
+		variant := string(runes[:i]) + string(runes[i+1:])
+		if len(variant) > 0 && !seenVariants[variant] {
+			seenVariants[variant] = true
+			variants = append(variants, variant)
+		}
+	}
+	return variants
+}
+
+func getBreakTerms(trade *Trade) [3]string {
+	return [3]string{trade.Currency, trade.SubmittedAdjustedMaturityDate.Format(DATE_FORMAT), trade.SubmittedNotional.String()}
+}
+
+func findBreakCandidates(breakTrade *Trade, cleanTrades []*Trade) []*breakCandidate {
+	candidates := make([]*breakCandidate, 0)
+	for _, cleanTrade := range cleanTrades {
+		if cleanTrade == breakTrade || cleanTrade.Party == breakTrade.Party {
+			continue
+		}
+		if candidate := getBreakCandidate(breakTrade, cleanTrade); candidate != nil {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].similarity > candidates[j].similarity
+	})
+	if len(candidates) > BREAK_CANDIDATES_LIMIT {
+		candidates = candidates[:BREAK_CANDIDATES_LIMIT]
+	}
+	return candidates
+}
+
+// getBreakCandidate scores how close the candidate is to the counterpart of the trade by the values both were
+// submitted with, it returns nil when the similarity is below BREAK_MIN_SIMILARITY
+func getBreakCandidate(trade *Trade, candidate *Trade) *breakCandidate {
+	similarity := 0.0
+	differences := make([]string, 0)
+
+	if candidate.Party == trade.Cpty {
+		similarity += PARTIES_WEIGHT / 2
+	} else {
+		differences = append(differences, fmt.Sprintf("Cpty %s vs Party %s", trade.Cpty, candidate.Party))
+	}
+	if candidate.Cpty == trade.Party {
+		similarity += PARTIES_WEIGHT / 2
+	} else {
+		differences = append(differences, fmt.Sprintf("Party %s vs Cpty %s", trade.Party, candidate.Cpty))
+	}
+
+	if candidate.PayOrReceive != trade.PayOrReceive {
+		similarity += PAY_OR_RECEIVE_WEIGHT
+	} else {
+		differences = append(differences, fmt.Sprintf("both PAY/RECEIVE %s", trade.PayOrReceive))
+	}
+
+	if candidate.Currency == trade.Currency {
+		similarity += CURRENCY_WEIGHT
+	} else {
+		differences = append(differences, fmt.Sprintf("Currency %s vs %s", trade.Currency, candidate.Currency))
+	}
+
+	if !candidate.SubmittedAdjustedMaturityDate.Equal(trade.SubmittedAdjustedMaturityDate) {
+		days := math.Abs(candidate.SubmittedAdjustedMaturityDate.Sub(trade.SubmittedAdjustedMaturityDate).Hours() / 24)
+		similarity += MATURITY_DATE_WEIGHT * math.Max(0, 1-days/MATURITY_DATE_SIMILARITY_DAYS)
+		differences = append(differences, fmt.Sprintf("MaturityDate %s vs %s", formatMaturityDate(trade), formatMaturityDate(candidate)))
+	} else {
+		similarity += MATURITY_DATE_WEIGHT
+	}
+
+	if candidate.SubmittedNotional.Cmp(trade.SubmittedNotional) != 0 {
+		similarity += NOTIONAL_WEIGHT * math.Max(0, 1-getRelativeDifference(trade.SubmittedNotional, candidate.SubmittedNotional)/NOTIONAL_SIMILARITY_RATIO)
+		differences = append(differences, fmt.Sprintf("Notional %s vs %s", trade.SubmittedNotional, candidate.SubmittedNotional))
+	} else {
+		similarity += NOTIONAL_WEIGHT
+	}
+
+	// the edit distance is only worked out when it can lift the similarity above the minimum
+	if similarity+CCP_TRADE_ID_WEIGHT < BREAK_MIN_SIMILARITY {
+		return nil
+	}
+	similarity += CCP_TRADE_ID_WEIGHT * getIDSimilarity(trade.CCPTradeID, candidate.CCPTradeID)
+	if candidate.CCPTradeID != trade.CCPTradeID {
+		differences = append([]string{fmt.Sprintf("CCPTradeID %s vs %s", trade.CCPTradeID, candidate.CCPTradeID)}, differences...)
+	}
+
+	if similarity < BREAK_MIN_SIMILARITY {
+		return nil
+	}
+	return &breakCandidate{trade: candidate, similarity: similarity, differences: differences}
+}
+
+// getIDSimilarity returns 1 for equal IDs down to 0 for IDs with nothing in common, an empty ID is never similar
+func getIDSimilarity(id1 string, id2 string) float64 {
+	if len(id1) == 0 || len(id2) == 0 {
+		return 0
+	}
+	length := math.Max(float64(len([]rune(id1))), float64(len([]rune(id2))))
+	return 1 - float64(toolkit.EditDistance(id1, id2))/length
+}
+
+func getRelativeDifference(notional1 toolkit.Decimal, notional2 toolkit.Decimal) float64 {
+	larger := notional1
+	if notional2.Cmp(notional1) > 0 {
+		larger = notional2
+	}
+	if larger.IsZero() {
+		return 0
+	}
+	difference := new(big.Rat).Sub(notional1.Rat(), notional2.Rat())
+	ratio, _ := new(big.Rat).Quo(difference.Abs(difference), larger.Rat()).Float64()
+	return ratio
+}
+
+// parseSimilarity reads a similarity like "87.50%" as 87.5, and an empty similarity as 0
+func parseSimilarity(similarity string) (float64, error) {
+	if len(similarity) == 0 {
+		return 0, nil
+	}
+	value, err := strconv.ParseFloat(strings.TrimSuffix(similarity, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("Similarity %s is not a percentage", similarity)
+	}
+	return value, nil
+}
+
+func compareTrades(trade1 *Trade, trade2 *Trade) bool {
+	if trade1.Party != trade2.Party {
+		return trade1.Party < trade2.Party
+	}
+	if trade1.TradeID != trade2.TradeID {
+		return trade1.TradeID < trade2.TradeID
+	}
+	if trade1.FileName != trade2.FileName {
+		return trade1.FileName < trade2.FileName
+	}
+	return trade1.RowNumber < trade2.RowNumber
+}
+
+func newBreakCandidate(trade *Trade) *BreakCandidate {
+	return &BreakCandidate{
+		Party:      trade.Party,
+		Book:       trade.Book,
+		TradeID:    trade.TradeID,
+		CCPTradeID: trade.CCPTradeID,
+		FileName:   trade.FileName,
+		RowNumber:  formatRowNumber(trade.RowNumber),
+	}
+}
+
+func (handler *MainHandler) GetBreakReport() []*BreakCandidate {
+	return handler.PortfolioLoader.BreakReport
+}
+
+func (handler *MainHandler) GetBreakReportAsCSV() (string, error) {
+	breakReport, err := gocsv.MarshalBytes(handler.GetBreakReport())
+	if err != nil {
+		return "", err
+	}
+
+	result := base64.StdEncoding.EncodeToString(breakReport)
+	return result, nil
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestBreakReportCandidates(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		candidate string
+	}{
+		{
+			name: "CCPTradeID with a substituted character",
+			content: "A,B1,T1,P,USD,2030/06/14,B,CCP100,1000000\n" +
+				"C,B3,V1,R,USD,2030/06/14,A,CCP10O,1000500\n",
+			candidate: "V1",
+		},
+		{
+			name: "CCPTradeID with an inserted character",
+			content: "A,B1,T1,P,USD,2030/06/14,B,CCP100,1000000\n" +
+				"C,B3,V1,R,USD,2030/06/14,A,CCP1000,1000500\n",
+			candidate: "V1",
+		},
+		{
+			name: "CCPTradeID with swapped characters",
+			content: "A,B1,T1,P,USD,2030/06/14,B,CCP120,1000000\n" +
+				"C,B3,V1,R,USD,2030/06/14,A,CCP210,1000500\n",
+			candidate: "V1",
+		},
+		{
+			name: "CCPTradeID with two substituted characters",
+			content: "A,B1,T1,P,USD,2030/06/14,B,CCP100,1000000\n" +
+				"C,B3,V1,R,USD,2030/06/14,A,CCP2O0,1000500\n",
+			candidate: "",
+		},
+		{
+			name: "same parties with a different CCPTradeID",
+			content: "A,B1,T1,P,USD,2030/06/14,B,CCP100,1000000\n" +
+				"B,B2,U1,R,USD,2030/06/14,A,XYZ,1000000\n",
+			candidate: "U1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			loader := loadTestPortfolio(t, test.content)
+
+			candidates := make([]string, 0)
+			for _, breakCandidate := range loader.BreakReport {
+				if breakCandidate.Party == "A" && breakCandidate.TradeID == "T1" && len(breakCandidate.CandidateTradeID) > 0 {
+					candidates = append(candidates, breakCandidate.CandidateTradeID)
+				}
+			}
+
+			if len(test.candidate) == 0 {
+				if len(candidates) > 0 {
+					t.Errorf("expected no candidate for T1, got %v", candidates)
+				}
+				return
+			}
+			if len(candidates) != 1 || candidates[0] != test.candidate {
+				t.Errorf("expected %s to be the candidate for T1, got %v", test.candidate, candidates)
+			}
+		})
+	}
+}
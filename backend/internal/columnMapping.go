@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/zytan787/code-to-connect-2021/api"
+	"io/ioutil"
+)
+
+// ColumnMapping maps a header of an input file to a column of RawTrade, like "Ccy" to "Currency"
+type ColumnMapping map[string]string
+
+// ColumnMappingProfiles holds a ColumnMapping per profile name, usually the name of the party sending the files
+type ColumnMappingProfiles map[string]ColumnMapping
+
+var DEFAULT_COLUMN_ALIASES = ColumnMapping{
+	"Direction":  "PAY/RECEIVE",
+	"Ccy":        "Currency",
+	"Maturity":   "MaturityDate",
+	"ClearingID": "CCPTradeID",
+}
+
+type InputFileOptions struct {
+	SheetName     string
+	ColumnMapping ColumnMapping
+	DateFormat    DateFormat
+	// MaxWholeFileSize limits the size in bytes of an XLSX or FpML file, which is read whole into memory
+	// unlike a CSV file, there is no limit when it is 0
+	MaxWholeFileSize int64
+}
+
+func LoadColumnMappingProfiles(path string) (ColumnMappingProfiles, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read column mapping profiles %s due to: %s", path, err.Error())
+	}
+
+	var profiles ColumnMappingProfiles
+	err = json.Unmarshal(content, &profiles)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse column mapping profiles %s due to: %s", path, err.Error())
+	}
+	return profiles, nil
+}
+
+// getInputFileOptions combines the column mapping of the request with the profile chosen by the file,
+// the profile wins when both map the same header
+func (handler *MainHandler) getInputFileOptions(inputFile api.File) (InputFileOptions, error) {
+	options := InputFileOptions{
+		SheetName:     inputFile.SheetName,
+		ColumnMapping: make(ColumnMapping, len(handler.ColumnMapping)),
+	}
+
+	dateFormat, err := ParseDateFormat(inputFile.DateFormat)
+	if err != nil {
+		return options, fmt.Errorf("unable to parse the date format of file %s due to: %s", inputFile.FileName, err.Error())
+	}
+	options.DateFormat = dateFormat
+
+	for header, column := range handler.ColumnMapping {
+		options.ColumnMapping[header] = column
+	}
+
+	if len(inputFile.ColumnMappingProfile) > 0 {
+		profile, ok := handler.ColumnMappingProfiles[inputFile.ColumnMappingProfile]
+		if !ok {
+			return options, fmt.Errorf("column mapping profile %s of file %s not found", inputFile.ColumnMappingProfile, inputFile.FileName)
+		}
+		for header, column := range profile {
+			options.ColumnMapping[header] = column
+		}
+	}
+
+	return options, nil
+}
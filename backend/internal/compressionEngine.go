@@ -1,10 +1,14 @@
 package internal
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"github.com/gocarina/gocsv"
+	"github.com/zytan787/code-to-connect-2021/internal/toolkit"
+	"math/big"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -13,22 +17,21 @@ type CompressionEngine struct {
 	BookLevelCompressionResults []*CompressionResultBookLevel
 }
 
-func (handler *MainHandler) GenerateCompressionResults() error {
+func (handler *MainHandler) GenerateCompressionResults(ctx context.Context) error {
 	keyToPayOrReceiveToTrades := handler.getKeyToPayOrReceiveToTrades(false)
 
 	compressionResults := make([]*CompressionResult, 0)
-	var originalPayNotional, originalReceiveNotional, newPayNotional, newReceiveNotional uint64
+	var originalPayNotional, originalReceiveNotional, newPayNotional, newReceiveNotional toolkit.Decimal
 	var trade *Trade
-	for _, payOrReceiveToTrades := range keyToPayOrReceiveToTrades {
-		originalPayNotional = sumNotional(payOrReceiveToTrades["P"])
-		originalReceiveNotional = sumNotional(payOrReceiveToTrades["R"])
-
-		if originalPayNotional > originalReceiveNotional {
-			newPayNotional = originalPayNotional - originalReceiveNotional
-			newReceiveNotional = 0
-		} else {
-			newReceiveNotional = originalReceiveNotional - originalPayNotional
-			newPayNotional = 0
+	var maturityDates string
+	var err error
+	for key, payOrReceiveToTrades := range keyToPayOrReceiveToTrades {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		originalPayNotional, newPayNotional, originalReceiveNotional, newReceiveNotional, err = netNotionals(payOrReceiveToTrades)
+		if err != nil {
+			return fmt.Errorf("unable to compress %s due to: %s", key, err.Error())
 		}
 
 		if len(payOrReceiveToTrades["P"]) > 0 {
@@ -36,27 +39,32 @@ func (handler *MainHandler) GenerateCompressionResults() error {
 		} else {
 			trade = payOrReceiveToTrades["R"][0]
 		}
+		maturityDates = getMaturityDates(payOrReceiveToTrades)
 
 		payCompressionResult := &CompressionResult{
-			Party:            trade.Party,
-			Currency:         trade.Currency,
-			MaturityDate:     trade.MaturityDate.Format(DATE_FORMAT),
-			PayOrReceive:     "P",
-			CompressionType:  generateCompressionType(newPayNotional),
-			OriginalNotional: fmt.Sprintf("%d", originalPayNotional),
-			Notional:         fmt.Sprintf("%d", newPayNotional),
-			CompressionRate:  generateCompressionRate(originalPayNotional, newPayNotional),
+			Party:                trade.Party,
+			Currency:             trade.Currency,
+			MaturityDate:         maturityDates,
+			AdjustedMaturityDate: trade.AdjustedMaturityDate.Format(DATE_FORMAT),
+			PayOrReceive:         "P",
+			CompressionType:      generateCompressionType(newPayNotional),
+			OriginalNotional:     originalPayNotional.String(),
+			Notional:             newPayNotional.String(),
+			CompressionRate:      generateCompressionRate(originalPayNotional, newPayNotional),
+			Warning:              getWarnings(payOrReceiveToTrades["P"]),
 		}
 
 		receiveCompressionResult := &CompressionResult{
-			Party:            trade.Party,
-			Currency:         trade.Currency,
-			MaturityDate:     trade.MaturityDate.Format(DATE_FORMAT),
-			PayOrReceive:     "R",
-			CompressionType:  generateCompressionType(newReceiveNotional),
-			OriginalNotional: fmt.Sprintf("%d", originalReceiveNotional),
-			Notional:         fmt.Sprintf("%d", newReceiveNotional),
-			CompressionRate:  generateCompressionRate(originalReceiveNotional, newReceiveNotional),
+			Party:                trade.Party,
+			Currency:             trade.Currency,
+			MaturityDate:         maturityDates,
+			AdjustedMaturityDate: trade.AdjustedMaturityDate.Format(DATE_FORMAT),
+			PayOrReceive:         "R",
+			CompressionType:      generateCompressionType(newReceiveNotional),
+			OriginalNotional:     originalReceiveNotional.String(),
+			Notional:             newReceiveNotional.String(),
+			CompressionRate:      generateCompressionRate(originalReceiveNotional, newReceiveNotional),
+			Warning:              getWarnings(payOrReceiveToTrades["R"]),
 		}
 
 		compressionResults = append(compressionResults, payCompressionResult)
@@ -67,22 +75,21 @@ func (handler *MainHandler) GenerateCompressionResults() error {
 	return nil
 }
 
-func (handler *MainHandler) GenerateBookLevelCompressionResults() error {
+func (handler *MainHandler) GenerateBookLevelCompressionResults(ctx context.Context) error {
 	bookLevelKeyToPayOrReceiveToTrades := handler.getKeyToPayOrReceiveToTrades(true)
 
 	bookLevelCompressionResults := make([]*CompressionResultBookLevel, 0)
-	var originalPayNotional, originalReceiveNotional, newPayNotional, newReceiveNotional uint64
+	var originalPayNotional, originalReceiveNotional, newPayNotional, newReceiveNotional toolkit.Decimal
 	var trade *Trade
-	for _, payOrReceiveToTrades := range bookLevelKeyToPayOrReceiveToTrades {
-		originalPayNotional = sumNotional(payOrReceiveToTrades["P"])
-		originalReceiveNotional = sumNotional(payOrReceiveToTrades["R"])
-
-		if originalPayNotional > originalReceiveNotional {
-			newPayNotional = originalPayNotional - originalReceiveNotional
-			newReceiveNotional = 0
-		} else {
-			newReceiveNotional = originalReceiveNotional - originalPayNotional
-			newPayNotional = 0
+	var maturityDates string
+	var err error
+	for key, payOrReceiveToTrades := range bookLevelKeyToPayOrReceiveToTrades {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		originalPayNotional, newPayNotional, originalReceiveNotional, newReceiveNotional, err = netNotionals(payOrReceiveToTrades)
+		if err != nil {
+			return fmt.Errorf("unable to compress %s due to: %s", key, err.Error())
 		}
 
 		if len(payOrReceiveToTrades["P"]) > 0 {
@@ -90,29 +97,34 @@ func (handler *MainHandler) GenerateBookLevelCompressionResults() error {
 		} else {
 			trade = payOrReceiveToTrades["R"][0]
 		}
+		maturityDates = getMaturityDates(payOrReceiveToTrades)
 
 		payCompressionResult := &CompressionResultBookLevel{
-			Party:            trade.Party,
-			Book:             trade.Book,
-			Currency:         trade.Currency,
-			MaturityDate:     trade.MaturityDate.Format(DATE_FORMAT),
-			PayOrReceive:     "P",
-			CompressionType:  generateCompressionType(newPayNotional),
-			OriginalNotional: fmt.Sprintf("%d", originalPayNotional),
-			Notional:         fmt.Sprintf("%d", newPayNotional),
-			CompressionRate:  generateCompressionRate(originalPayNotional, newPayNotional),
+			Party:                trade.Party,
+			Book:                 trade.Book,
+			Currency:             trade.Currency,
+			MaturityDate:         maturityDates,
+			AdjustedMaturityDate: trade.AdjustedMaturityDate.Format(DATE_FORMAT),
+			PayOrReceive:         "P",
+			CompressionType:      generateCompressionType(newPayNotional),
+			OriginalNotional:     originalPayNotional.String(),
+			Notional:             newPayNotional.String(),
+			CompressionRate:      generateCompressionRate(originalPayNotional, newPayNotional),
+			Warning:              getWarnings(payOrReceiveToTrades["P"]),
 		}
 
 		receiveCompressionResult := &CompressionResultBookLevel{
-			Party:            trade.Party,
-			Book:             trade.Book,
-			Currency:         trade.Currency,
-			MaturityDate:     trade.MaturityDate.Format(DATE_FORMAT),
-			PayOrReceive:     "R",
-			CompressionType:  generateCompressionType(newReceiveNotional),
-			OriginalNotional: fmt.Sprintf("%d", originalReceiveNotional),
-			Notional:         fmt.Sprintf("%d", newReceiveNotional),
-			CompressionRate:  generateCompressionRate(originalReceiveNotional, newReceiveNotional),
+			Party:                trade.Party,
+			Book:                 trade.Book,
+			Currency:             trade.Currency,
+			MaturityDate:         maturityDates,
+			AdjustedMaturityDate: trade.AdjustedMaturityDate.Format(DATE_FORMAT),
+			PayOrReceive:         "R",
+			CompressionType:      generateCompressionType(newReceiveNotional),
+			OriginalNotional:     originalReceiveNotional.String(),
+			Notional:             newReceiveNotional.String(),
+			CompressionRate:      generateCompressionRate(originalReceiveNotional, newReceiveNotional),
+			Warning:              getWarnings(payOrReceiveToTrades["R"]),
 		}
 
 		bookLevelCompressionResults = append(bookLevelCompressionResults, payCompressionResult)
@@ -148,19 +160,47 @@ func (handler *MainHandler) getKeyToPayOrReceiveToTrades(bookLevel bool) map[str
 }
 
 func generateKeyFromTrade(trade *Trade, bookLevel bool) string {
-	key := fmt.Sprintf(KEY_FORMAT, trade.Party, trade.Currency, trade.MaturityDate.Format(DATE_FORMAT))
+	key := fmt.Sprintf(KEY_FORMAT, trade.Party, trade.Currency, trade.AdjustedMaturityDate.Format(DATE_FORMAT))
 	if bookLevel {
 		return fmt.Sprintf("%s_%s", key, trade.Book)
 	}
 	return key
 }
 
-func generateCompressionRate(originalNotional, newNotional uint64) string {
-	if originalNotional == 0 {
-		return "100%"
+// getMaturityDates lists the maturity dates the trades of a key were submitted with, trades submitted with
+// different dates share a key when the dates are adjusted to the same business day
+func getMaturityDates(payOrReceiveToTrades map[string][]*Trade) string {
+	seenMaturityDates := make(map[string]bool)
+	maturityDates := make([]string, 0, 1)
+	for _, payOrReceive := range []string{"P", "R"} {
+		for _, trade := range payOrReceiveToTrades[payOrReceive] {
+			maturityDate := trade.MaturityDate.Format(DATE_FORMAT)
+			if !seenMaturityDates[maturityDate] {
+				seenMaturityDates[maturityDate] = true
+				maturityDates = append(maturityDates, maturityDate)
+			}
+		}
 	}
+	sort.Strings(maturityDates)
+	return strings.Join(maturityDates, "; ")
+}
 
-	compressionRate := (float64(originalNotional) - float64(newNotional)) / float64(originalNotional) * 100
+// getWarnings joins the distinct warnings of the trades, like the trades paired within tolerance
+func getWarnings(trades []*Trade) string {
+	seenWarnings := make(map[string]bool)
+	warnings := make([]string, 0)
+	for _, trade := range trades {
+		if len(trade.Warning) > 0 && !seenWarnings[trade.Warning] {
+			seenWarnings[trade.Warning] = true
+			warnings = append(warnings, trade.Warning)
+		}
+	}
+	sort.Strings(warnings)
+	return strings.Join(warnings, "; ")
+}
+
+func generateCompressionRate(originalNotional, newNotional toolkit.Decimal) string {
+	compressionRate := calculateCompressionRate(originalNotional, newNotional)
 
 	if compressionRate == 100 {
 		return "100%"
@@ -168,22 +208,61 @@ func generateCompressionRate(originalNotional, newNotional uint64) string {
 	return fmt.Sprintf("%.2f%%", compressionRate)
 }
 
-func generateCompressionType(newNotional uint64) CompressionType {
-	if newNotional == 0 {
+// calculateCompressionRate computes the rate exactly and only converts the result to float64
+func calculateCompressionRate(originalNotional, newNotional toolkit.Decimal) float64 {
+	if originalNotional.IsZero() {
+		return 100
+	}
+
+	compressionRate := new(big.Rat).Sub(originalNotional.Rat(), newNotional.Rat())
+	compressionRate.Quo(compressionRate, originalNotional.Rat())
+	compressionRate.Mul(compressionRate, big.NewRat(100, 1))
+	rate, _ := compressionRate.Float64()
+	return rate
+}
+
+func generateCompressionType(newNotional toolkit.Decimal) CompressionType {
+	if newNotional.IsZero() {
 		return TERMINATION
 	}
 	return PARTIAL
 }
 
-func sumNotional(trades []*Trade) uint64 {
-	var sum uint64 = 0
+// netNotionals sums the paying and receiving notionals of a key and nets them, only the larger side keeps a notional.
+// It returns the original and new paying notionals followed by the original and new receiving notionals.
+func netNotionals(payOrReceiveToTrades map[string][]*Trade) (toolkit.Decimal, toolkit.Decimal, toolkit.Decimal, toolkit.Decimal, error) {
+	var newPayNotional, newReceiveNotional toolkit.Decimal
+
+	originalPayNotional, err := sumNotional(payOrReceiveToTrades["P"])
+	if err != nil {
+		return newPayNotional, newPayNotional, newPayNotional, newPayNotional, err
+	}
+	originalReceiveNotional, err := sumNotional(payOrReceiveToTrades["R"])
+	if err != nil {
+		return newPayNotional, newPayNotional, newPayNotional, newPayNotional, err
+	}
+
+	if originalPayNotional.Cmp(originalReceiveNotional) > 0 {
+		newPayNotional, err = originalPayNotional.Sub(originalReceiveNotional)
+	} else {
+		newReceiveNotional, err = originalReceiveNotional.Sub(originalPayNotional)
+	}
+	return originalPayNotional, newPayNotional, originalReceiveNotional, newReceiveNotional, err
+}
+
+func sumNotional(trades []*Trade) (toolkit.Decimal, error) {
+	var sum toolkit.Decimal
+	var err error
 	for _, trade := range trades {
-		sum += trade.Notional
+		sum, err = sum.Add(trade.Notional)
+		if err != nil {
+			return sum, fmt.Errorf("sum of notionals overflows at trade %s: %s", trade.TradeID, err.Error())
+		}
 	}
-	return sum
+	return sum, nil
 }
 
-func (handler *MainHandler) GetCompressionReportAsCSV() (string, error) {
+func (handler *MainHandler) GetCompressionResults() []*CompressionResult {
 	compressionResults := handler.CompressionEngine.CompressionResults
 
 	sort.Slice(compressionResults, func(i, j int) bool {
@@ -193,14 +272,20 @@ func (handler *MainHandler) GetCompressionReportAsCSV() (string, error) {
 		if compressionResults[i].Currency != compressionResults[j].Currency {
 			return compressionResults[i].Currency < compressionResults[j].Currency
 		}
-		if compressionResults[i].MaturityDate != compressionResults[j].MaturityDate {
-			timeI, _ := time.Parse(DATE_FORMAT, compressionResults[i].MaturityDate)
-			timeJ, _ := time.Parse(DATE_FORMAT, compressionResults[j].MaturityDate)
+		if compressionResults[i].AdjustedMaturityDate != compressionResults[j].AdjustedMaturityDate {
+			timeI, _ := time.Parse(DATE_FORMAT, compressionResults[i].AdjustedMaturityDate)
+			timeJ, _ := time.Parse(DATE_FORMAT, compressionResults[j].AdjustedMaturityDate)
 			return timeI.Before(timeJ)
 		}
 		return compressionResults[i].PayOrReceive < compressionResults[j].PayOrReceive
 	})
 
+	return compressionResults
+}
+
+func (handler *MainHandler) GetCompressionReportAsCSV() (string, error) {
+	compressionResults := handler.GetCompressionResults()
+
 	compressionResultsBytes, err := gocsv.MarshalBytes(compressionResults)
 	if err != nil {
 		return "", err
@@ -210,7 +295,7 @@ func (handler *MainHandler) GetCompressionReportAsCSV() (string, error) {
 	return result, nil
 }
 
-func (handler *MainHandler) GetCompressionReportBookLevelAsCSV() (string, error) {
+func (handler *MainHandler) GetBookLevelCompressionResults() []*CompressionResultBookLevel {
 	bookLevelCompressionResults := handler.CompressionEngine.BookLevelCompressionResults
 
 	sort.Slice(bookLevelCompressionResults, func(i, j int) bool {
@@ -223,14 +308,20 @@ func (handler *MainHandler) GetCompressionReportBookLevelAsCSV() (string, error)
 		if bookLevelCompressionResults[i].Currency != bookLevelCompressionResults[j].Currency {
 			return bookLevelCompressionResults[i].Currency < bookLevelCompressionResults[j].Currency
 		}
-		if bookLevelCompressionResults[i].MaturityDate != bookLevelCompressionResults[j].MaturityDate {
-			timeI, _ := time.Parse(DATE_FORMAT, bookLevelCompressionResults[i].MaturityDate)
-			timeJ, _ := time.Parse(DATE_FORMAT, bookLevelCompressionResults[j].MaturityDate)
+		if bookLevelCompressionResults[i].AdjustedMaturityDate != bookLevelCompressionResults[j].AdjustedMaturityDate {
+			timeI, _ := time.Parse(DATE_FORMAT, bookLevelCompressionResults[i].AdjustedMaturityDate)
+			timeJ, _ := time.Parse(DATE_FORMAT, bookLevelCompressionResults[j].AdjustedMaturityDate)
 			return timeI.Before(timeJ)
 		}
 		return bookLevelCompressionResults[i].PayOrReceive < bookLevelCompressionResults[j].PayOrReceive
 	})
 
+	return bookLevelCompressionResults
+}
+
+func (handler *MainHandler) GetCompressionReportBookLevelAsCSV() (string, error) {
+	bookLevelCompressionResults := handler.GetBookLevelCompressionResults()
+
 	bookLevelCompressionResultsBytes, err := gocsv.MarshalBytes(bookLevelCompressionResults)
 	if err != nil {
 		return "", err
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const DEFAULT_MINOR_UNITS = 2
+
+// iso4217.csv lists the active ISO 4217 currencies with their minor units, currencies without minor units
+// like precious metals are left out
+//
+//go:embed iso4217.csv
+var iso4217CSV []byte
+
+// ISO_4217_MINOR_UNITS holds the number of decimal places of every ISO 4217 currency
+var ISO_4217_MINOR_UNITS = loadISO4217MinorUnits(iso4217CSV)
+
+func loadISO4217MinorUnits(content []byte) map[string]int {
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		panic(fmt.Sprintf("unable to read the ISO 4217 table due to: %s", err.Error()))
+	}
+
+	minorUnits := make(map[string]int, len(records))
+	for _, record := range records[1:] {
+		minorUnits[record[0]], err = strconv.Atoi(record[1])
+		if err != nil {
+			panic(fmt.Sprintf("unable to read the minor units of currency %s due to: %s", record[0], err.Error()))
+		}
+	}
+	return minorUnits
+}
+
+// NormalizeCurrency returns the currency code in upper case without surrounding spaces, like "USD" for " usd"
+func NormalizeCurrency(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+func IsISO4217Currency(currency string) bool {
+	_, ok := ISO_4217_MINOR_UNITS[NormalizeCurrency(currency)]
+	return ok
+}
+
+// ParseAllowedCurrencies reads a comma separated list of currency codes like "USD,EUR,JPY",
+// it returns nil when the list is empty, which allows every ISO 4217 currency
+func ParseAllowedCurrencies(value string) (map[string]bool, error) {
+	if len(strings.TrimSpace(value)) == 0 {
+		return nil, nil
+	}
+
+	allowedCurrencies := make(map[string]bool)
+	for _, currency := range strings.Split(value, ",") {
+		currency = NormalizeCurrency(currency)
+		if !IsISO4217Currency(currency) {
+			return nil, fmt.Errorf("allowed currency %s is not an ISO 4217 currency code", currency)
+		}
+		allowedCurrencies[currency] = true
+	}
+	return allowedCurrencies, nil
+}
+
+// validateCurrency checks that a normalized currency is an ISO 4217 code within the allowed currencies,
+// every ISO 4217 currency is allowed when allowedCurrencies is empty
+func validateCurrency(currency string, allowedCurrencies map[string]bool) error {
+	if !IsISO4217Currency(currency) {
+		return fmt.Errorf("Currency %s is not an ISO 4217 currency code", currency)
+	}
+	if len(allowedCurrencies) > 0 && !allowedCurrencies[currency] {
+		return fmt.Errorf("Currency %s is not an allowed currency", currency)
+	}
+	return nil
+}
+
+// GetMinorUnits returns the number of decimal places of a currency, notionals are rounded to it
+func GetMinorUnits(currency string) int {
+	if minorUnits, ok := ISO_4217_MINOR_UNITS[NormalizeCurrency(currency)]; ok {
+		return minorUnits
+	}
+	return DEFAULT_MINOR_UNITS
+}
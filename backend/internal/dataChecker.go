@@ -2,13 +2,18 @@ package internal
 
 import (
 	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"github.com/gocarina/gocsv"
 	"github.com/zytan787/code-to-connect-2021/api"
 	"sort"
 )
 
+const DATA_CHECK_TOTAL_PARTY = "Total"
+
 type DataChecker struct {
 	PartyToDataCheckResult map[string]*DataCheckResult
+	DataCheckTotal         *DataCheckResult
 }
 
 func (handler *MainHandler) CheckData() error {
@@ -19,34 +24,81 @@ func (handler *MainHandler) CheckData() error {
 	}
 
 	partyToDataCheckResult := make(map[string]*DataCheckResult)
-	var totalIn, totalOut, originalNotional, notional uint64
+	var err error
 	for party, proposals := range partyToProposals {
-		totalIn, totalOut, originalNotional, notional = 0, 0, 0, 0
+		dataCheckResult := &DataCheckResult{Party: party}
 		for _, proposal := range proposals {
-			if proposal.Action != ADD {
-				if proposal.PayOrReceive == "P" {
-					totalOut += proposal.Notional
-				} else {
-					totalIn += proposal.Notional
-				}
-				originalNotional += proposal.Notional
-			}
-			if proposal.Action != CANCEL {
-				notional += proposal.Notional
+			if err = dataCheckResult.addProposal(proposal); err != nil {
+				return fmt.Errorf("unable to check the proposals of party %s due to: %s", party, err.Error())
 			}
 		}
-		partyToDataCheckResult[party] = &DataCheckResult{
-			Party:            party,
-			TotalIn:          totalIn,
-			TotalOut:         totalOut,
-			NetOut:           int(totalOut) - int(totalIn),
-			OriginalNotional: originalNotional,
-			Notional:         notional,
-			Reduced:          notional < originalNotional,
+		if err = dataCheckResult.complete(); err != nil {
+			return fmt.Errorf("unable to check the proposals of party %s due to: %s", party, err.Error())
+		}
+		partyToDataCheckResult[party] = dataCheckResult
+	}
+
+	dataCheckTotal := &DataCheckResult{Party: DATA_CHECK_TOTAL_PARTY}
+	for _, dataCheckResult := range partyToDataCheckResult {
+		if err = dataCheckTotal.addResult(dataCheckResult); err != nil {
+			return fmt.Errorf("unable to check the total of every party due to: %s", err.Error())
 		}
 	}
+	if err = dataCheckTotal.complete(); err != nil {
+		return fmt.Errorf("unable to check the total of every party due to: %s", err.Error())
+	}
 
 	handler.DataChecker.PartyToDataCheckResult = partyToDataCheckResult
+	handler.DataChecker.DataCheckTotal = dataCheckTotal
+	return nil
+}
+
+// addProposal adds a CXL proposal to the totals in and out and to the original notional, and an ADD proposal to the notional
+func (result *DataCheckResult) addProposal(proposal *Proposal) error {
+	var err error
+	if proposal.Action != ADD {
+		if proposal.PayOrReceive == "P" {
+			result.TotalOut, err = result.TotalOut.Add(proposal.Notional)
+		} else {
+			result.TotalIn, err = result.TotalIn.Add(proposal.Notional)
+		}
+		if err != nil {
+			return err
+		}
+		if result.OriginalNotional, err = result.OriginalNotional.Add(proposal.Notional); err != nil {
+			return err
+		}
+	}
+	if proposal.Action != CANCEL {
+		if result.Notional, err = result.Notional.Add(proposal.Notional); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (result *DataCheckResult) addResult(other *DataCheckResult) error {
+	var err error
+	if result.TotalIn, err = result.TotalIn.Add(other.TotalIn); err != nil {
+		return err
+	}
+	if result.TotalOut, err = result.TotalOut.Add(other.TotalOut); err != nil {
+		return err
+	}
+	if result.OriginalNotional, err = result.OriginalNotional.Add(other.OriginalNotional); err != nil {
+		return err
+	}
+	result.Notional, err = result.Notional.Add(other.Notional)
+	return err
+}
+
+// complete computes NetOut and Reduced from the totals
+func (result *DataCheckResult) complete() error {
+	var err error
+	if result.NetOut, err = result.TotalOut.Sub(result.TotalIn); err != nil {
+		return err
+	}
+	result.Reduced = result.Notional.Cmp(result.OriginalNotional) < 0
 	return nil
 }
 
@@ -69,8 +121,8 @@ func (handler *MainHandler) GetStatistics() []api.Statistic {
 	for party, dataCheckResult := range handler.DataChecker.PartyToDataCheckResult {
 		result[i] = api.Statistic{
 			Party:              party,
-			OriginalNotional:   dataCheckResult.OriginalNotional,
-			NewNotional:        dataCheckResult.Notional,
+			OriginalNotional:   json.Number(dataCheckResult.OriginalNotional.String()),
+			NewNotional:        json.Number(dataCheckResult.Notional.String()),
 			OriginalNoOfTrades: partyToOriginalTradeCount[party],
 			NewNoOfTrades:      partyToNewTradeCount[party],
 		}
@@ -84,7 +136,7 @@ func (handler *MainHandler) GetStatistics() []api.Statistic {
 	return result
 }
 
-func (handler *MainHandler) GetDataCheckResultsAsCSV() (string, error) {
+func (handler *MainHandler) GetDataCheckResults() []*DataCheckResult {
 	dataCheckResults := make([]*DataCheckResult, len(handler.DataChecker.PartyToDataCheckResult))
 
 	i := 0
@@ -97,26 +149,15 @@ func (handler *MainHandler) GetDataCheckResultsAsCSV() (string, error) {
 		return dataCheckResults[i].Party < dataCheckResults[j].Party
 	})
 
-	var totalIn, totalOut, originalNotional, notional uint64
-
-	for _, dataCheckResult := range dataCheckResults {
-		totalIn += dataCheckResult.TotalIn
-		totalOut += dataCheckResult.TotalOut
-		originalNotional += dataCheckResult.OriginalNotional
-		notional += dataCheckResult.Notional
-	}
+	return dataCheckResults
+}
 
-	totalCheckResult := &DataCheckResult{
-		Party:            "Total",
-		TotalIn:          totalIn,
-		TotalOut:         totalOut,
-		NetOut:           int(totalOut) - int(totalIn),
-		OriginalNotional: originalNotional,
-		Notional:         notional,
-		Reduced:          notional < originalNotional,
-	}
+func (handler *MainHandler) GetDataCheckTotal() *DataCheckResult {
+	return handler.DataChecker.DataCheckTotal
+}
 
-	dataCheckResults = append(dataCheckResults, totalCheckResult)
+func (handler *MainHandler) GetDataCheckResultsAsCSV() (string, error) {
+	dataCheckResults := append(handler.GetDataCheckResults(), handler.GetDataCheckTotal())
 
 	dataCheckResultsBytes, err := gocsv.MarshalBytes(dataCheckResults)
 	if err != nil {
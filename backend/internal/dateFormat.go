@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/araddon/dateparse"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateFormat is the order of day and month in the maturity dates of a file or party
+type DateFormat string
+
+const (
+	DATE_FORMAT_AUTO DateFormat = ""
+	DATE_FORMAT_ISO  DateFormat = "ISO"
+	DATE_FORMAT_DMY  DateFormat = "DMY"
+	DATE_FORMAT_MDY  DateFormat = "MDY"
+)
+
+// a year first date like 2025/04/03, 2025-04-03 or 20250403 is never ambiguous
+var yearFirstDatePattern = regexp.MustCompile(`^(\d{4})[-/.](\d{1,2})[-/.](\d{1,2})$|^(\d{4})(\d{2})(\d{2})$`)
+var dayOrMonthFirstDatePattern = regexp.MustCompile(`^(\d{1,2})[-/.](\d{1,2})[-/.](\d{4}|\d{2})$`)
+
+// DateOptions holds the date format of each party and whether dates that are ambiguous or do not match
+// their format are rejected instead of being parsed by guessing
+type DateOptions struct {
+	PartyDateFormats map[string]DateFormat
+	Strict           bool
+}
+
+func ParseDateFormat(format string) (DateFormat, error) {
+	switch DateFormat(strings.ToUpper(strings.TrimSpace(format))) {
+	case DATE_FORMAT_AUTO:
+		return DATE_FORMAT_AUTO, nil
+	case DATE_FORMAT_ISO:
+		return DATE_FORMAT_ISO, nil
+	case DATE_FORMAT_DMY:
+		return DATE_FORMAT_DMY, nil
+	case DATE_FORMAT_MDY:
+		return DATE_FORMAT_MDY, nil
+	}
+	return DATE_FORMAT_AUTO, fmt.Errorf("date format %s is neither '%s', '%s' or '%s'", format,
+		DATE_FORMAT_ISO, DATE_FORMAT_DMY, DATE_FORMAT_MDY)
+}
+
+func ParseDateOptions(partyDateFormats map[string]string, strict bool) (DateOptions, error) {
+	options := DateOptions{
+		PartyDateFormats: make(map[string]DateFormat, len(partyDateFormats)),
+		Strict:           strict,
+	}
+
+	for party, format := range partyDateFormats {
+		dateFormat, err := ParseDateFormat(format)
+		if err != nil {
+			return options, fmt.Errorf("unable to parse the date format of party %s due to: %s", party, err.Error())
+		}
+		options.PartyDateFormats[strings.TrimSpace(party)] = dateFormat
+	}
+	return options, nil
+}
+
+// getDateFormat returns the date format of the file of the trade, or else the date format of its party
+func (options DateOptions) getDateFormat(rawTrade *RawTrade, party string) DateFormat {
+	if rawTrade.DateFormat != DATE_FORMAT_AUTO {
+		return rawTrade.DateFormat
+	}
+	return options.PartyDateFormats[party]
+}
+
+// parseMaturityDate reads the day and month of a date like 03/04/2025 in the order given by the date format.
+// Without a format such a date is ambiguous when both numbers can be a month, it is then rejected in strict mode
+// and read month first otherwise. Year first and textual dates are read the same way whatever the format.
+func (options DateOptions) parseMaturityDate(value string, format DateFormat) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	invalidDateErr := fmt.Errorf("fail to parse MaturityDate %s, date is invalid", value)
+
+	if match := yearFirstDatePattern.FindStringSubmatch(value); match != nil {
+		if match[1] == "" {
+			return newDate(match[4], match[5], match[6], invalidDateErr)
+		}
+		return newDate(match[1], match[2], match[3], invalidDateErr)
+	}
+
+	match := dayOrMonthFirstDatePattern.FindStringSubmatch(value)
+	if match == nil {
+		maturityDate, err := dateparse.ParseAny(value)
+		if err != nil {
+			return time.Time{}, invalidDateErr
+		}
+		return maturityDate, nil
+	}
+
+	year := match[3]
+	if len(year) == 2 {
+		year = "20" + year
+	}
+
+	switch format {
+	case DATE_FORMAT_DMY:
+		return newDate(year, match[2], match[1], invalidDateErr)
+	case DATE_FORMAT_MDY:
+		return newDate(year, match[1], match[2], invalidDateErr)
+	case DATE_FORMAT_ISO:
+		if options.Strict {
+			return time.Time{}, fmt.Errorf("MaturityDate %s does not match date format %s", value, format)
+		}
+	}
+
+	if options.Strict && match[1] != match[2] && isMonth(match[1]) && isMonth(match[2]) {
+		return time.Time{}, fmt.Errorf("MaturityDate %s is an ambiguous date, set the date format of its file or party to %s or %s",
+			value, DATE_FORMAT_DMY, DATE_FORMAT_MDY)
+	}
+	if isMonth(match[1]) {
+		return newDate(year, match[1], match[2], invalidDateErr)
+	}
+	return newDate(year, match[2], match[1], invalidDateErr)
+}
+
+func isMonth(value string) bool {
+	month, err := strconv.Atoi(value)
+	return err == nil && month >= 1 && month <= 12
+}
+
+// newDate returns the date or invalidDateErr when the day does not exist, instead of rolling over like time.Date
+func newDate(year string, month string, day string, invalidDateErr error) (time.Time, error) {
+	y, _ := strconv.Atoi(year)
+	m, _ := strconv.Atoi(month)
+	d, _ := strconv.Atoi(day)
+
+	date := time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+	if date.Year() != y || int(date.Month()) != m || date.Day() != d {
+		return time.Time{}, invalidDateErr
+	}
+	return date, nil
+}
@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestParseMaturityDate(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		format   DateFormat
+		strict   bool
+		expected string
+	}{
+		{"ambiguous date read month first", "03/04/2025", DATE_FORMAT_AUTO, false, "2025/03/04"},
+		{"ambiguous date rejected in strict mode", "03/04/2025", DATE_FORMAT_AUTO, true, ""},
+		{"ambiguous date with DMY", "03/04/2025", DATE_FORMAT_DMY, true, "2025/04/03"},
+		{"ambiguous date with MDY", "03/04/2025", DATE_FORMAT_MDY, true, "2025/03/04"},
+		{"ambiguous date with ISO", "03/04/2025", DATE_FORMAT_ISO, false, "2025/03/04"},
+		{"ambiguous date with ISO in strict mode", "03/04/2025", DATE_FORMAT_ISO, true, ""},
+		{"same day and month in strict mode", "04/04/2025", DATE_FORMAT_AUTO, true, "2025/04/04"},
+		{"day first date in strict mode", "13/04/2025", DATE_FORMAT_AUTO, true, "2025/04/13"},
+		{"month first date in strict mode", "04/13/2025", DATE_FORMAT_AUTO, true, "2025/04/13"},
+		{"two digit year", "03-04-25", DATE_FORMAT_DMY, true, "2025/04/03"},
+		{"year first date with DMY", "2025/04/03", DATE_FORMAT_DMY, true, "2025/04/03"},
+		{"year first date without separators", "20250403", DATE_FORMAT_MDY, true, "2025/04/03"},
+		{"day that does not exist", "31/02/2025", DATE_FORMAT_DMY, false, ""},
+		{"day and month that do not exist", "13/13/2025", DATE_FORMAT_AUTO, false, ""},
+		{"not a date", "soon", DATE_FORMAT_AUTO, false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := DateOptions{Strict: test.strict}
+			maturityDate, err := options.parseMaturityDate(test.value, test.format)
+			if len(test.expected) == 0 {
+				if err == nil {
+					t.Errorf("expected an error parsing %s, got %s", test.value, maturityDate.Format(DATE_FORMAT))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error parsing %s, got %s", test.value, err.Error())
+			}
+			if maturityDate.Format(DATE_FORMAT) != test.expected {
+				t.Errorf("expected %s to be parsed as %s, got %s", test.value, test.expected, maturityDate.Format(DATE_FORMAT))
+			}
+		})
+	}
+}
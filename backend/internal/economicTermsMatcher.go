@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SYNTHETIC_CCP_TRADE_ID_PREFIX starts the CCPTradeID given to two trades without CCPTradeID paired by their economic terms
+const SYNTHETIC_CCP_TRADE_ID_PREFIX = "MATCH"
+
+// SYNTHETIC_CCP_TRADE_ID_HASH_BYTES is the number of bytes of the hash of the paired trades written in a synthetic CCPTradeID
+const SYNTHETIC_CCP_TRADE_ID_HASH_BYTES = 8
+
+// economicTerms are the terms both sides of a trade have in common, the notional is compared separately
+type economicTerms struct {
+	payer        string
+	receiver     string
+	currency     string
+	maturityDate string
+}
+
+func newEconomicTerms(trade *Trade) economicTerms {
+	terms := economicTerms{
+		payer:        trade.Party,
+		receiver:     trade.Cpty,
+		currency:     trade.Currency,
+		maturityDate: trade.AdjustedMaturityDate.Format(DATE_FORMAT),
+	}
+	if trade.PayOrReceive == "R" {
+		terms.payer, terms.receiver = trade.Cpty, trade.Party
+	}
+	return terms
+}
+
+// matchByEconomicTerms pairs every trade without CCPTradeID with the trade whose economic terms mirror its own:
+// Party and Cpty swapped, opposite PayOrReceive, same currency, adjusted maturity date and notional.
+// The other trade either has no CCPTradeID, then both get a synthetic CCPTradeID, or it has a CCPTradeID that no
+// other trade has, then both share it. A match is only made when both trades match nothing else, trades without
+// CCPTradeID that match no trade or several trades are excluded, and so are trades with a CCPTradeID matching several.
+func (loader *PortfolioLoader) matchByEconomicTerms(exclusionReasons map[*Trade]string) []*ExcludedTrade {
+	candidateTrades := removeExcludedTrades(loader.cleanTradesWithoutCCPTradeID, exclusionReasons)
+	if len(candidateTrades) == 0 {
+		return nil
+	}
+
+	ccpTradeIDs := make([]string, 0)
+	for ccpTradeID, cleanTrades := range loader.ccpTradeIDToCleanTrades {
+		if len(cleanTrades) == 1 && len(removeExcludedTrades(cleanTrades, exclusionReasons)) == 1 {
+			ccpTradeIDs = append(ccpTradeIDs, ccpTradeID)
+		}
+	}
+	sort.Strings(ccpTradeIDs)
+	for _, ccpTradeID := range ccpTradeIDs {
+		candidateTrades = append(candidateTrades, loader.ccpTradeIDToCleanTrades[ccpTradeID][0])
+	}
+
+	termsToTrades := make(map[economicTerms][]*Trade)
+	for _, trade := range candidateTrades {
+		terms := newEconomicTerms(trade)
+		termsToTrades[terms] = append(termsToTrades[terms], trade)
+	}
+
+	excludedTrades := make([]*ExcludedTrade, 0)
+	matchedTrades := make(map[*Trade]bool)
+	for _, trade := range candidateTrades {
+		if matchedTrades[trade] {
+			continue
+		}
+
+		var errorMessage string
+		matchingTrades := getMatchingTrades(termsToTrades, trade)
+		switch {
+		case len(matchingTrades) == 0:
+			if len(trade.CCPTradeID) > 0 {
+				// left to be excluded as not submitted on both sides
+				continue
+			}
+			errorMessage = "trade has no CCPTradeID and no trade matches its economic terms"
+			loader.breakTrades = append(loader.breakTrades, trade)
+		case len(matchingTrades) > 1:
+			errorMessage = fmt.Sprintf("%s match more than one trade: %s",
+				getEconomicTermsSubject(trade), describeTrades(matchingTrades))
+		default:
+			competingTrades := removeTrade(getMatchingTrades(termsToTrades, matchingTrades[0]), trade)
+			if len(competingTrades) > 0 {
+				errorMessage = fmt.Sprintf("%s match %s, which also matches %s",
+					getEconomicTermsSubject(trade), describeTrades(matchingTrades), describeTrades(competingTrades))
+			}
+		}
+
+		if len(errorMessage) > 0 {
+			excludedTrades = append(excludedTrades, createExcludedTradeFromCleanTrades([]*Trade{trade}, errorMessage)...)
+			exclusionReasons[trade] = "ambiguous economic terms"
+			continue
+		}
+
+		loader.pairByEconomicTerms(trade, matchingTrades[0])
+		matchedTrades[trade] = true
+		matchedTrades[matchingTrades[0]] = true
+	}
+	return excludedTrades
+}
+
+// getMatchingTrades returns the trades mirroring the economic terms of the trade, two trades with a CCPTradeID never match
+func getMatchingTrades(termsToTrades map[economicTerms][]*Trade, trade *Trade) []*Trade {
+	matchingTrades := make([]*Trade, 0)
+	for _, otherTrade := range termsToTrades[newEconomicTerms(trade)] {
+		if otherTrade.PayOrReceive == trade.PayOrReceive || otherTrade.Notional.Cmp(trade.Notional) != 0 {
+			continue
+		}
+		if len(otherTrade.CCPTradeID) > 0 && len(trade.CCPTradeID) > 0 {
+			continue
+		}
+		matchingTrades = append(matchingTrades, otherTrade)
+	}
+	return matchingTrades
+}
+
+// pairByEconomicTerms gives both trades the same CCPTradeID, so that they are verified and compressed like any pair
+func (loader *PortfolioLoader) pairByEconomicTerms(trade1 *Trade, trade2 *Trade) {
+	ccpTradeID := trade1.CCPTradeID + trade2.CCPTradeID
+	warning := fmt.Sprintf("trade without CCPTradeID is paired by its economic terms with CCPTradeID=%s", ccpTradeID)
+	if len(ccpTradeID) == 0 {
+		ccpTradeID = getSyntheticCCPTradeID(trade1, trade2)
+		warning = fmt.Sprintf("trades without CCPTradeID are paired by their economic terms as CCPTradeID=%s", ccpTradeID)
+	}
+
+	for _, trade := range []*Trade{trade1, trade2} {
+		trade.CCPTradeID = ccpTradeID
+		trade.Warning = warning
+	}
+	loader.ccpTradeIDToCleanTrades[ccpTradeID] = []*Trade{trade1, trade2}
+}
+
+// getSyntheticCCPTradeID derives the CCPTradeID of two paired trades from their parties and TradeIDs, so that
+// the same input files always give the same proposals
+func getSyntheticCCPTradeID(trade1 *Trade, trade2 *Trade) string {
+	keys := []string{trade1.Party + "\x00" + trade1.TradeID, trade2.Party + "\x00" + trade2.TradeID}
+	sort.Strings(keys)
+	hash := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+	return fmt.Sprintf("%s%X", SYNTHETIC_CCP_TRADE_ID_PREFIX, hash[:SYNTHETIC_CCP_TRADE_ID_HASH_BYTES])
+}
+
+func getEconomicTermsSubject(trade *Trade) string {
+	if len(trade.CCPTradeID) == 0 {
+		return "trade has no CCPTradeID and its economic terms"
+	}
+	return "economic terms of the trade"
+}
+
+// describeTrades names the trades like "TradeID T1 of party A at a.csv row 2 and TradeID U1 of party B"
+func describeTrades(trades []*Trade) string {
+	descriptions := make([]string, len(trades))
+	for i, trade := range trades {
+		descriptions[i] = fmt.Sprintf("TradeID %s of party %s", trade.TradeID, trade.Party)
+		if location := getTradeLocation(trade); len(location) > 0 {
+			descriptions[i] = fmt.Sprintf("%s at %s", descriptions[i], location)
+		}
+	}
+	if len(descriptions) == 1 {
+		return descriptions[0]
+	}
+	return fmt.Sprintf("%s and %s", strings.Join(descriptions[:len(descriptions)-1], ", "), descriptions[len(descriptions)-1])
+}
+
+func removeTrade(trades []*Trade, trade *Trade) []*Trade {
+	result := make([]*Trade, 0, len(trades))
+	for _, otherTrade := range trades {
+		if otherTrade != trade {
+			result = append(result, otherTrade)
+		}
+	}
+	return result
+}
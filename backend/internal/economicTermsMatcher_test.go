@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+const TEST_INPUT_HEADER = "Party,Book,TradeID,PAY/RECEIVE,Currency,MaturityDate,Cpty,CCPTradeID,Notional\n"
+
+func loadTestPortfolio(t *testing.T, content string) *PortfolioLoader {
+	t.Helper()
+
+	handler := NewMainHandler()
+	handler.StartLoadingPortfolio()
+	if err := handler.LoadInputFile("input.csv", InputFileOptions{}, strings.NewReader(TEST_INPUT_HEADER+content)); err != nil {
+		t.Fatal(err)
+	}
+	handler.FinishLoadingPortfolio()
+	return handler.PortfolioLoader
+}
+
+func TestMatchByEconomicTerms(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		pairs    map[string]string
+		excluded map[string]string
+	}{
+		{
+			name: "one to one without CCPTradeID",
+			content: "A,B1,T1,P,USD,2030/06/14,B,,1000000\n" +
+				"B,B2,U1,R,USD,2030/06/14,A,,1000000\n",
+			pairs: map[string]string{"A T1": "B U1"},
+		},
+		{
+			name: "one to one with a CCPTradeID submitted on one side",
+			content: "A,B1,T1,R,USD,2030/06/14,B,C1,500000\n" +
+				"B,B2,U1,P,USD,2030/06/14,A,,500000\n",
+			pairs: map[string]string{"A T1": "B U1"},
+		},
+		{
+			name: "one without CCPTradeID to many",
+			content: "A,B1,T1,P,EUR,2030/06/14,B,,200000\n" +
+				"B,B2,U1,R,EUR,2030/06/14,A,,200000\n" +
+				"B,B2,U2,R,EUR,2030/06/14,A,,200000\n",
+			excluded: map[string]string{
+				"A T1": "trade has no CCPTradeID and its economic terms match more than one trade",
+				"B U1": "which also matches TradeID U2 of party B",
+				"B U2": "which also matches TradeID U1 of party B",
+			},
+		},
+		{
+			name: "one with CCPTradeID to many",
+			content: "A,B1,T1,P,JPY,2030/06/14,C,C1,900000\n" +
+				"C,B3,V1,R,JPY,2030/06/14,A,,900000\n" +
+				"C,B3,V2,R,JPY,2030/06/14,A,,900000\n",
+			excluded: map[string]string{
+				"A T1": "economic terms of the trade match more than one trade",
+				"C V1": "which also matches TradeID V2 of party C",
+				"C V2": "which also matches TradeID V1 of party C",
+			},
+		},
+		{
+			name: "one to many with one pair kept apart by its notional",
+			content: "A,B1,T1,P,USD,2030/06/14,B,,100\n" +
+				"B,B2,U1,R,USD,2030/06/14,A,,100\n" +
+				"B,B2,U2,R,USD,2030/06/14,A,,101\n",
+			pairs:    map[string]string{"A T1": "B U1"},
+			excluded: map[string]string{"B U2": "trade has no CCPTradeID and no trade matches its economic terms"},
+		},
+		{
+			name: "two trades with different CCPTradeIDs",
+			content: "A,B1,T1,P,USD,2030/06/14,B,C1,100\n" +
+				"B,B2,U1,R,USD,2030/06/14,A,C2,100\n",
+			excluded: map[string]string{
+				"A T1": "trade not submitted on both sides",
+				"B U1": "trade not submitted on both sides",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			loader := loadTestPortfolio(t, test.content)
+
+			tradeToCCPTradeID := make(map[string]string)
+			for ccpTradeID, trades := range loader.CcpTradeIDToCompressibleTrades {
+				for _, trade := range trades {
+					tradeToCCPTradeID[trade.Party+" "+trade.TradeID] = ccpTradeID
+				}
+			}
+			if len(tradeToCCPTradeID) != 2*len(test.pairs) {
+				t.Errorf("expected %d paired trades, got %v", 2*len(test.pairs), tradeToCCPTradeID)
+			}
+			for trade1, trade2 := range test.pairs {
+				if ccpTradeID, ok := tradeToCCPTradeID[trade1]; !ok || tradeToCCPTradeID[trade2] != ccpTradeID {
+					t.Errorf("expected %s to be paired with %s, got %v", trade1, trade2, tradeToCCPTradeID)
+				}
+			}
+
+			tradeToError := make(map[string]string)
+			for _, excludedTrade := range loader.ExcludedTrades {
+				tradeToError[excludedTrade.Party+" "+excludedTrade.TradeID] = excludedTrade.Error
+			}
+			if len(tradeToError) != len(test.excluded) {
+				t.Errorf("expected %d excluded trades, got %v", len(test.excluded), tradeToError)
+			}
+			for trade, expectedError := range test.excluded {
+				if !strings.Contains(tradeToError[trade], expectedError) {
+					t.Errorf("expected %s to be excluded with %q, got %q", trade, expectedError, tradeToError[trade])
+				}
+			}
+		})
+	}
+}
+
+func TestSyntheticCCPTradeID(t *testing.T) {
+	content := "A,B1,T1,P,USD,2030/06/14,B,,1000000\n" +
+		"B,B2,U1,R,USD,2030/06/14,A,,1000000\n"
+	reversedContent := "B,B2,U1,R,USD,2030/06/14,A,,1000000\n" +
+		"A,B1,T1,P,USD,2030/06/14,B,,1000000\n"
+
+	ccpTradeIDs := make([]string, 0)
+	for _, loader := range []*PortfolioLoader{loadTestPortfolio(t, content), loadTestPortfolio(t, content), loadTestPortfolio(t, reversedContent)} {
+		for ccpTradeID := range loader.CcpTradeIDToCompressibleTrades {
+			ccpTradeIDs = append(ccpTradeIDs, ccpTradeID)
+		}
+	}
+
+	if len(ccpTradeIDs) != 3 || !strings.HasPrefix(ccpTradeIDs[0], SYNTHETIC_CCP_TRADE_ID_PREFIX) {
+		t.Fatalf("expected one synthetic CCPTradeID per run, got %v", ccpTradeIDs)
+	}
+	if ccpTradeIDs[1] != ccpTradeIDs[0] || ccpTradeIDs[2] != ccpTradeIDs[0] {
+		t.Errorf("expected the same synthetic CCPTradeID on every run, got %v", ccpTradeIDs)
+	}
+}
@@ -3,7 +3,6 @@ package internal
 import (
 	"encoding/base64"
 	"fmt"
-	"github.com/gocarina/gocsv"
 	"github.com/zytan787/code-to-connect-2021/api"
 	"github.com/zytan787/code-to-connect-2021/internal/toolkit"
 	"sort"
@@ -34,7 +33,7 @@ func (handler *MainHandler) GenerateProposals() error {
 		if _, ok := keyToDefaultBook[key]; !ok {
 			keyToDefaultBook[key] = proposal.Book
 		}
-		keyWithoutParty = fmt.Sprintf(KEY_WITHOUT_PARTY_FORMAT, proposal.Currency, proposal.MaturityDate)
+		keyWithoutParty = fmt.Sprintf(KEY_WITHOUT_PARTY_FORMAT, proposal.Currency, proposal.AdjustedMaturityDate)
 		if _, ok := keyWithoutPartyToDefaultCPty[keyWithoutParty]; !ok {
 			keyWithoutPartyToDefaultCPty[keyWithoutParty] = proposal.Party
 		}
@@ -46,7 +45,7 @@ func (handler *MainHandler) GenerateProposals() error {
 		if _, ok := keyToDefaultBook[key]; !ok {
 			keyToDefaultBook[key] = proposal.Book
 		}
-		keyWithoutParty = fmt.Sprintf(KEY_WITHOUT_PARTY_FORMAT, proposal.Currency, proposal.MaturityDate)
+		keyWithoutParty = fmt.Sprintf(KEY_WITHOUT_PARTY_FORMAT, proposal.Currency, proposal.AdjustedMaturityDate)
 		if _, ok := keyWithoutPartyToDefaultCPty[keyWithoutParty]; !ok {
 			keyWithoutPartyToDefaultCPty[keyWithoutParty] = proposal.Party
 		}
@@ -56,14 +55,18 @@ func (handler *MainHandler) GenerateProposals() error {
 	handler.EventGenerator.KeyToDefaultBook = keyToDefaultBook
 
 	// retrieve required notional for each key
-	keyToNotional := make(map[string]int)
-	var notional int
+	keyToNotional := make(map[string]toolkit.Decimal)
+	var notional toolkit.Decimal
+	var err error
 	for _, compressionResult := range handler.CompressionEngine.CompressionResults {
-		key = fmt.Sprintf(KEY_FORMAT, compressionResult.Party, compressionResult.Currency, compressionResult.MaturityDate)
+		key = fmt.Sprintf(KEY_FORMAT, compressionResult.Party, compressionResult.Currency, compressionResult.AdjustedMaturityDate)
 		if compressionResult.CompressionType != TERMINATION {
-			notional, _ = strconv.Atoi(compressionResult.Notional)
+			notional, err = toolkit.ParseDecimal(compressionResult.Notional)
+			if err != nil {
+				return fmt.Errorf("unable to read the target notional of %s due to: %s", key, err.Error())
+			}
 			if compressionResult.PayOrReceive == "P" {
-				keyToNotional[key] = -notional
+				keyToNotional[key] = notional.Neg()
 			} else {
 				keyToNotional[key] = notional
 			}
@@ -78,7 +81,7 @@ func (handler *MainHandler) GenerateProposals() error {
 		keyWithoutParty = strings.Join(splitKey[len(splitKey)-2:], "_")
 		party = strings.Join(splitKey[:len(splitKey)-2], "_") // for cases where party name contains "_"
 		if keyWithoutPartyToDefaultCPty[keyWithoutParty] != party {
-			if keyToNotional[key] > 0 {
+			if keyToNotional[key].Sign() > 0 {
 				payOrReceive = "R"
 			} else {
 				payOrReceive = "P"
@@ -89,12 +92,12 @@ func (handler *MainHandler) GenerateProposals() error {
 			maturityDate = splitKey[len(splitKey)-1]
 			handler.EventGenerator.addPairedProposalsForNewTrade(
 				party, payOrReceive, currency,
-				maturityDate, defaultCPty, uint64(abs(keyToNotional[key])))
+				maturityDate, defaultCPty, keyToNotional[key].Abs())
 		}
 	}
 
 	// minimize total notional
-	var target int
+	var target toolkit.Decimal
 	var proposals, payingProposals, receivingProposals []*Proposal
 	for keyWithoutParty, defaultCPty = range keyWithoutPartyToDefaultCPty {
 		key = fmt.Sprintf("%s_%s", defaultCPty, keyWithoutParty)
@@ -117,21 +120,29 @@ func (handler *MainHandler) GenerateProposals() error {
 		payingProposals = sortProposalsByNotional(payingProposals, true)
 		receivingProposals = sortProposalsByNotional(receivingProposals, true)
 
-		if target < 0 {
-			handler.EventGenerator.minimizeNotionalRecursively(payingProposals, receivingProposals, uint64(abs(target)), "P", "")
-		} else if target > 0 {
-			handler.EventGenerator.minimizeNotionalRecursively(payingProposals, receivingProposals, uint64(target), "R", "")
+		var err error
+		if target.Sign() < 0 {
+			err = handler.EventGenerator.minimizeNotionalRecursively(payingProposals, receivingProposals, target.Abs(), "P", "")
+		} else if target.Sign() > 0 {
+			err = handler.EventGenerator.minimizeNotionalRecursively(payingProposals, receivingProposals, target, "R", "")
+		}
+		if err != nil {
+			return fmt.Errorf("unable to minimize the notional of %s due to: %s", key, err.Error())
 		}
 	}
 
+	handler.EventGenerator.fillNewTradeAttributes(handler.AttributeRule)
+
 	return nil
 }
 
-func (eventGenerator *EventGenerator) minimizeNotionalRecursively(payingProposals []*Proposal, receivingProposals []*Proposal, amount uint64, payOrReceive string, originalCPty string) {
-	if amount == 0 {
-		return
+func (eventGenerator *EventGenerator) minimizeNotionalRecursively(payingProposals []*Proposal, receivingProposals []*Proposal, amount toolkit.Decimal, payOrReceive string, originalCPty string) error {
+	if amount.IsZero() {
+		return nil
 	}
 
+	var err error
+
 	var eligibleProposals []*Proposal
 	if payOrReceive == "P" {
 		eligibleProposals = payingProposals
@@ -140,38 +151,49 @@ func (eventGenerator *EventGenerator) minimizeNotionalRecursively(payingProposal
 	}
 
 	for i := 0; i < len(eligibleProposals); i++ {
-		if eligibleProposals[i].Notional >= amount {
+		if eligibleProposals[i].Notional.Cmp(amount) >= 0 {
 
 			// if the notional is equal to the required amount, but there are still extra trades left
-			if eligibleProposals[i].Notional == amount {
-				extra := sumProposalsNotional(eligibleProposals[i+1:])
-				if extra != 0 {
-					amount -= extra
+			if eligibleProposals[i].Notional.Cmp(amount) == 0 {
+				extra, err := sumProposalsNotional(eligibleProposals[i+1:])
+				if err != nil {
+					return err
+				}
+				if !extra.IsZero() {
+					if amount, err = amount.Sub(extra); err != nil {
+						return err
+					}
 				}
 			}
 
-			remaining := eligibleProposals[i].Notional - amount
+			remaining, err := eligibleProposals[i].Notional.Sub(amount)
+			if err != nil {
+				return err
+			}
 			if len(originalCPty) > 0 {
 				eventGenerator.changeCPty(eligibleProposals[i].CCPTradeID, eligibleProposals[i].Cpty, originalCPty)
 			}
 			eventGenerator.changeNotional(eligibleProposals[i].CCPTradeID, amount)
 			originalCPty = eligibleProposals[i].Cpty
 			if payOrReceive == "P" {
-				eventGenerator.minimizeNotionalRecursively(eligibleProposals[i+1:], receivingProposals, remaining, "R", originalCPty)
+				return eventGenerator.minimizeNotionalRecursively(eligibleProposals[i+1:], receivingProposals, remaining, "R", originalCPty)
 			} else if payOrReceive == "R" {
-				eventGenerator.minimizeNotionalRecursively(payingProposals, eligibleProposals[i+1:], remaining, "P", originalCPty)
+				return eventGenerator.minimizeNotionalRecursively(payingProposals, eligibleProposals[i+1:], remaining, "P", originalCPty)
 			}
-			return
+			return nil
 		} else {
-			amount -= eligibleProposals[i].Notional
+			if amount, err = amount.Sub(eligibleProposals[i].Notional); err != nil {
+				return err
+			}
 			if len(originalCPty) > 0 {
 				eventGenerator.changeCPty(eligibleProposals[i].CCPTradeID, eligibleProposals[i].Cpty, originalCPty)
 			}
 		}
 	}
+	return nil
 }
 
-func (eventGenerator *EventGenerator) changeNotional(ccpTradeID string, newNotional uint64) {
+func (eventGenerator *EventGenerator) changeNotional(ccpTradeID string, newNotional toolkit.Decimal) {
 	for _, proposal := range eventGenerator.CcpTradeIDToProposals[ccpTradeID] {
 		proposal.Notional = newNotional
 	}
@@ -185,7 +207,7 @@ func (eventGenerator *EventGenerator) changeCPty(ccpTradeID string, party string
 			proposal.Cpty = newCpty
 			newProposals[0] = proposal
 		} else {
-			key := fmt.Sprintf(KEY_FORMAT, proposal.Party, proposal.Currency, proposal.MaturityDate)
+			key := fmt.Sprintf(KEY_FORMAT, proposal.Party, proposal.Currency, proposal.AdjustedMaturityDate)
 			initialPartyProposals := eventGenerator.KeyToProposals[key]
 			for i := 0; i < len(initialPartyProposals); i++ {
 				if initialPartyProposals[i] == proposal {
@@ -195,8 +217,8 @@ func (eventGenerator *EventGenerator) changeCPty(ccpTradeID string, party string
 			}
 			newProposal := eventGenerator.generateProposalForNewTrade(
 				newCpty, proposal.PayOrReceive, proposal.Currency,
-				proposal.MaturityDate, party, ccpTradeID, proposal.Notional)
-			newKey := fmt.Sprintf(KEY_FORMAT, newProposal.Party, newProposal.Currency, newProposal.MaturityDate)
+				proposal.AdjustedMaturityDate, party, ccpTradeID, proposal.Notional)
+			newKey := fmt.Sprintf(KEY_FORMAT, newProposal.Party, newProposal.Currency, newProposal.AdjustedMaturityDate)
 			eventGenerator.KeyToProposals[newKey] = append(eventGenerator.KeyToProposals[newKey], newProposal)
 			newProposals[1] = newProposal
 		}
@@ -207,16 +229,19 @@ func (eventGenerator *EventGenerator) changeCPty(ccpTradeID string, party string
 
 func createNewProposalFromTrade(trade *Trade) *Proposal {
 	return &Proposal{
-		Party:        trade.Party,
-		Book:         trade.Book,
-		TradeID:      trade.TradeID,
-		PayOrReceive: trade.PayOrReceive,
-		Currency:     trade.Currency,
-		MaturityDate: trade.MaturityDate.Format(DATE_FORMAT),
-		Cpty:         trade.Cpty,
-		CCPTradeID:   trade.CCPTradeID,
-		Notional:     trade.Notional,
-		Action:       CANCEL,
+		Party:                trade.Party,
+		Book:                 trade.Book,
+		TradeID:              trade.TradeID,
+		PayOrReceive:         trade.PayOrReceive,
+		Currency:             trade.Currency,
+		MaturityDate:         trade.MaturityDate.Format(DATE_FORMAT),
+		AdjustedMaturityDate: trade.AdjustedMaturityDate.Format(DATE_FORMAT),
+		Cpty:                 trade.Cpty,
+		CCPTradeID:           trade.CCPTradeID,
+		Notional:             trade.Notional,
+		Action:               CANCEL,
+		Warning:              trade.Warning,
+		Attributes:           trade.Attributes,
 	}
 }
 
@@ -232,24 +257,27 @@ func filterProposalsByActionType(proposals []*Proposal, actionType ActionType) [
 	return result
 }
 
-func sumProposalsNotional(proposals []*Proposal) uint64 {
-	var sum uint64 = 0
+func sumProposalsNotional(proposals []*Proposal) (toolkit.Decimal, error) {
+	var sum toolkit.Decimal
+	var err error
 
 	for _, proposal := range proposals {
-		sum += proposal.Notional
+		if sum, err = sum.Add(proposal.Notional); err != nil {
+			return sum, err
+		}
 	}
 
-	return sum
+	return sum, nil
 }
 
 func sortProposalsByNotional(proposals []*Proposal, descending bool) []*Proposal {
 	if descending {
 		sort.Slice(proposals, func(i, j int) bool {
-			return proposals[i].Notional > proposals[j].Notional
+			return proposals[i].Notional.Cmp(proposals[j].Notional) > 0
 		})
 	} else {
 		sort.Slice(proposals, func(i, j int) bool {
-			return proposals[i].Notional < proposals[j].Notional
+			return proposals[i].Notional.Cmp(proposals[j].Notional) < 0
 		})
 	}
 
@@ -257,7 +285,7 @@ func sortProposalsByNotional(proposals []*Proposal, descending bool) []*Proposal
 }
 
 func (eventGenerator *EventGenerator) addPairedProposalsForNewTrade(
-	party string, payOrReceive string, currency string, maturityDate string, cPty string, notional uint64) {
+	party string, payOrReceive string, currency string, maturityDate string, cPty string, notional toolkit.Decimal) {
 
 	ccpTradeID := fmt.Sprintf("%s%s", CCPTRADEID_PREFIX, toolkit.UniqueID())
 
@@ -268,8 +296,8 @@ func (eventGenerator *EventGenerator) addPairedProposalsForNewTrade(
 	proposal2 := eventGenerator.generateProposalForNewTrade(
 		cPty, payOrReceive, currency, maturityDate, party, ccpTradeID, notional)
 
-	key1 := fmt.Sprintf(KEY_FORMAT, proposal1.Party, proposal1.Currency, proposal1.MaturityDate)
-	key2 := fmt.Sprintf(KEY_FORMAT, proposal2.Party, proposal2.Currency, proposal2.MaturityDate)
+	key1 := fmt.Sprintf(KEY_FORMAT, proposal1.Party, proposal1.Currency, proposal1.AdjustedMaturityDate)
+	key2 := fmt.Sprintf(KEY_FORMAT, proposal2.Party, proposal2.Currency, proposal2.AdjustedMaturityDate)
 
 	eventGenerator.KeyToProposals[key1] = append(eventGenerator.KeyToProposals[key1], proposal1)
 	eventGenerator.KeyToProposals[key2] = append(eventGenerator.KeyToProposals[key2], proposal2)
@@ -278,42 +306,51 @@ func (eventGenerator *EventGenerator) addPairedProposalsForNewTrade(
 
 func (eventGenerator *EventGenerator) generateProposalForNewTrade(
 	party string, payOrReceive string, currency string,
-	maturityDate string, cPty string, ccpTradeID string, notional uint64) *Proposal {
+	maturityDate string, cPty string, ccpTradeID string, notional toolkit.Decimal) *Proposal {
 
 	newTradeProposal := &Proposal{
-		Party:        party,
-		Book:         fmt.Sprintf("%s", eventGenerator.KeyToDefaultBook[fmt.Sprintf(KEY_FORMAT, party, currency, maturityDate)]),
-		TradeID:      fmt.Sprintf("%s%s", party, toolkit.UniqueID()),
-		PayOrReceive: payOrReceive,
-		Currency:     currency,
-		MaturityDate: maturityDate,
-		Cpty:         cPty,
-		CCPTradeID:   ccpTradeID,
-		Notional:     notional,
-		Action:       ADD,
+		Party:                party,
+		Book:                 fmt.Sprintf("%s", eventGenerator.KeyToDefaultBook[fmt.Sprintf(KEY_FORMAT, party, currency, maturityDate)]),
+		TradeID:              fmt.Sprintf("%s%s", party, toolkit.UniqueID()),
+		PayOrReceive:         payOrReceive,
+		Currency:             currency,
+		MaturityDate:         maturityDate,
+		AdjustedMaturityDate: maturityDate,
+		Cpty:                 cPty,
+		CCPTradeID:           ccpTradeID,
+		Notional:             notional,
+		Action:               ADD,
 	}
 
 	return newTradeProposal
 }
 
-func (handler *MainHandler) GetProposalsAsCSV() ([]api.Proposal, error) {
+func (handler *MainHandler) GetPartyToProposals() map[string][]*Proposal {
 	partyToProposals := make(map[string][]*Proposal)
 
 	for _, proposals := range handler.EventGenerator.KeyToProposals {
 		partyToProposals[proposals[0].Party] = append(partyToProposals[proposals[0].Party], proposals...)
 	}
 
-	result := make([]api.Proposal, len(partyToProposals))
-
-	i := 0
 	for party, proposals := range partyToProposals {
 		sort.Slice(proposals, func(i, j int) bool {
 			indexI, _ := strconv.Atoi(proposals[i].TradeID[len(party):])
 			indexJ, _ := strconv.Atoi(proposals[j].TradeID[len(party):])
 			return indexI < indexJ
 		})
+	}
+
+	return partyToProposals
+}
 
-		proposalsBytes, err := gocsv.MarshalBytes(proposals)
+func (handler *MainHandler) GetProposalsAsCSV() ([]api.Proposal, error) {
+	partyToProposals := handler.GetPartyToProposals()
+
+	result := make([]api.Proposal, len(partyToProposals))
+
+	i := 0
+	for party, proposals := range partyToProposals {
+		proposalsBytes, err := MarshalProposalsCSV(proposals)
 		if err != nil {
 			return nil, err
 		}
@@ -329,13 +366,6 @@ func (handler *MainHandler) GetProposalsAsCSV() ([]api.Proposal, error) {
 	return result, nil
 }
 
-func abs(x int) int {
-	if x < 0 {
-		return -x
-	}
-	return x
-}
-
 func getOppositePayOrReceive(payOrReceive string) string {
 	if payOrReceive == "P" {
 		return "R"
@@ -0,0 +1,287 @@
+package internal
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/zytan787/code-to-connect-2021/api"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const FPML_NAMESPACE = "http://www.fpml.org/FpML-5/confirmation"
+const FPML_VERSION = "5-10"
+const FPML_DATE_FORMAT = "2006-01-02"
+const FPML_TRADE_ID_SCHEME = "trade-id"
+const FPML_CLEARING_TRADE_ID_SCHEME = "clearing-trade-id"
+const FPML_COMPRESSION_TYPE = "PortfolioCompression"
+const FPML_PARTY_ID = "party"
+const FPML_CPTY_ID = "counterparty"
+const FPML_ACCOUNT_ID = "book"
+const FPML_MESSAGE_FILE_FORMAT = "%04d_%s_%s.xml"
+
+type fpmlHeader struct {
+	MessageID         string        `xml:"messageId"`
+	OnBehalfOf        fpmlReference `xml:"onBehalfOf>partyReference"`
+	CreationTimestamp string        `xml:"creationTimestamp"`
+}
+
+type fpmlTradeIdentifier struct {
+	PartyReference fpmlReference `xml:"partyReference"`
+	TradeIDs       []fpmlTradeID `xml:"tradeId"`
+}
+
+type fpmlCompressionActivity struct {
+	CompressionType             string                `xml:"compressionType"`
+	ReplacementTradeIdentifiers []fpmlTradeIdentifier `xml:"replacementTradeIdentifier"`
+	CompressedTradeIdentifiers  []fpmlTradeIdentifier `xml:"compressedTradeIdentifier"`
+}
+
+type fpmlMoney struct {
+	Currency string `xml:"currency"`
+	Amount   string `xml:"amount"`
+}
+
+type fpmlNewTrade struct {
+	PartyTradeIdentifier fpmlPartyTradeIdentifier `xml:"tradeHeader>partyTradeIdentifier"`
+	TradeDate            string                   `xml:"tradeHeader>tradeDate"`
+	SwapStreams          []fpmlSwapStream         `xml:"swap>swapStream"`
+	CompressionActivity  fpmlCompressionActivity  `xml:"compressionActivity"`
+}
+
+type fpmlTermination struct {
+	TradeIdentifier           fpmlTradeIdentifier     `xml:"tradeIdentifier"`
+	AgreementDate             string                  `xml:"agreementDate"`
+	EffectiveDate             string                  `xml:"effectiveDate"`
+	ChangeInNotionalAmount    fpmlMoney               `xml:"changeInNotionalAmount"`
+	OutstandingNotionalAmount fpmlMoney               `xml:"outstandingNotionalAmount"`
+	CompressionActivity       fpmlCompressionActivity `xml:"compressionActivity"`
+}
+
+type fpmlMessage struct {
+	XMLName      xml.Name         `xml:"requestConfirmation"`
+	Namespace    string           `xml:"xmlns,attr"`
+	FpMLVersion  string           `xml:"fpmlVersion,attr"`
+	Header       fpmlHeader       `xml:"header"`
+	IsCorrection bool             `xml:"isCorrection"`
+	Trade        *fpmlNewTrade    `xml:"trade"`
+	Termination  *fpmlTermination `xml:"termination"`
+	Parties      []fpmlParty      `xml:"party"`
+	Accounts     []fpmlAccount    `xml:"account"`
+}
+
+type fpmlFile struct {
+	fileName string
+	message  *fpmlMessage
+}
+
+// WriteProposalsFpML writes a ZIP with one FpML message per proposal of the party: a termination for each CXL
+// and a new trade for each ADD. The messages of a currency and maturity date refer to each other through their
+// compressionActivity, a termination lists the new trades replacing it and a new trade lists the trades it compresses.
+// The messages target the FpML 5.10 confirmation view but are not valid against its schema: the input trades carry
+// no fixed rate, floating rate index or schedule dates, so the two legs of a new trade only have their payer,
+// receiver, notional and termination date, without a fixedRateSchedule or floatingRateCalculation.
+func WriteProposalsFpML(w io.Writer, requestID string, createdAt time.Time, proposals []*Proposal) error {
+	files, err := createFpMLFiles(requestID, createdAt, proposals)
+	if err != nil {
+		return err
+	}
+	return writeFpMLFiles(w, files)
+}
+
+func createFpMLFiles(requestID string, createdAt time.Time, proposals []*Proposal) ([]fpmlFile, error) {
+	groupToProposals := make(map[string][]*Proposal)
+	for _, proposal := range proposals {
+		group := fmt.Sprintf(KEY_WITHOUT_PARTY_FORMAT, proposal.Currency, proposal.AdjustedMaturityDate)
+		groupToProposals[group] = append(groupToProposals[group], proposal)
+	}
+
+	files := make([]fpmlFile, len(proposals))
+	for i, proposal := range proposals {
+		group := fmt.Sprintf(KEY_WITHOUT_PARTY_FORMAT, proposal.Currency, proposal.AdjustedMaturityDate)
+		message, err := createFpMLMessage(requestID, createdAt, proposal, groupToProposals[group])
+		if err != nil {
+			return nil, fmt.Errorf("unable to create FpML message for trade %s due to: %s", proposal.TradeID, err.Error())
+		}
+		files[i] = fpmlFile{
+			fileName: fmt.Sprintf(FPML_MESSAGE_FILE_FORMAT, i+1, proposal.Action, url.PathEscape(proposal.TradeID)),
+			message:  message,
+		}
+	}
+	return files, nil
+}
+
+func writeFpMLFiles(w io.Writer, files []fpmlFile) error {
+	zipWriter := zip.NewWriter(w)
+	for _, file := range files {
+		content, err := xml.MarshalIndent(file.message, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err = writeZipFile(zipWriter, file.fileName, append([]byte(xml.Header), content...)); err != nil {
+			return err
+		}
+	}
+	return zipWriter.Close()
+}
+
+func createFpMLMessage(requestID string, createdAt time.Time, proposal *Proposal, groupProposals []*Proposal) (*fpmlMessage, error) {
+	maturityDate, err := time.Parse(DATE_FORMAT, proposal.MaturityDate)
+	if err != nil {
+		return nil, err
+	}
+
+	message := &fpmlMessage{
+		Namespace:   FPML_NAMESPACE,
+		FpMLVersion: FPML_VERSION,
+		Header: fpmlHeader{
+			MessageID:         fmt.Sprintf("%s-%s-%s", requestID, proposal.Action, proposal.TradeID),
+			OnBehalfOf:        fpmlReference{Href: FPML_PARTY_ID},
+			CreationTimestamp: createdAt.Format(time.RFC3339),
+		},
+		Parties: []fpmlParty{
+			{ID: FPML_PARTY_ID, PartyID: proposal.Party},
+			{ID: FPML_CPTY_ID, PartyID: proposal.Cpty},
+		},
+	}
+
+	compressionActivity := fpmlCompressionActivity{CompressionType: FPML_COMPRESSION_TYPE}
+
+	if proposal.Action == CANCEL {
+		for _, groupProposal := range groupProposals {
+			if groupProposal.Action == ADD {
+				compressionActivity.ReplacementTradeIdentifiers = append(compressionActivity.ReplacementTradeIdentifiers,
+					createFpMLTradeIdentifier(groupProposal))
+			}
+		}
+
+		// a CXL terminates the whole notional of the trade
+		terminatedNotional := proposal.Notional
+		outstandingNotional, err := proposal.Notional.Sub(terminatedNotional)
+		if err != nil {
+			return nil, err
+		}
+
+		message.Termination = &fpmlTermination{
+			TradeIdentifier:           createFpMLTradeIdentifier(proposal),
+			AgreementDate:             createdAt.Format(FPML_DATE_FORMAT),
+			EffectiveDate:             createdAt.Format(FPML_DATE_FORMAT),
+			ChangeInNotionalAmount:    fpmlMoney{Currency: proposal.Currency, Amount: terminatedNotional.String()},
+			OutstandingNotionalAmount: fpmlMoney{Currency: proposal.Currency, Amount: outstandingNotional.String()},
+			CompressionActivity:       compressionActivity,
+		}
+		return message, nil
+	}
+
+	for _, groupProposal := range groupProposals {
+		if groupProposal.Action == CANCEL {
+			compressionActivity.CompressedTradeIdentifiers = append(compressionActivity.CompressedTradeIdentifiers,
+				createFpMLTradeIdentifier(groupProposal))
+		}
+	}
+
+	// the party pays or receives the first leg as given by the proposal, and the counterparty the second leg
+	payer, receiver := FPML_PARTY_ID, FPML_CPTY_ID
+	if proposal.PayOrReceive == "R" {
+		payer, receiver = FPML_CPTY_ID, FPML_PARTY_ID
+	}
+	notional := fpmlNotionalStepSchedule{
+		InitialValue: proposal.Notional.String(),
+		Currency:     proposal.Currency,
+	}
+
+	message.Trade = &fpmlNewTrade{
+		PartyTradeIdentifier: fpmlPartyTradeIdentifier{
+			PartyReference:   fpmlReference{Href: FPML_PARTY_ID},
+			AccountReference: fpmlReference{Href: FPML_ACCOUNT_ID},
+			TradeIDs:         createFpMLTradeIDs(proposal),
+		},
+		TradeDate: createdAt.Format(FPML_DATE_FORMAT),
+		SwapStreams: []fpmlSwapStream{
+			{
+				PayerPartyReference:    fpmlReference{Href: payer},
+				ReceiverPartyReference: fpmlReference{Href: receiver},
+				TerminationDate:        maturityDate.Format(FPML_DATE_FORMAT),
+				Notional:               notional,
+			},
+			{
+				PayerPartyReference:    fpmlReference{Href: receiver},
+				ReceiverPartyReference: fpmlReference{Href: payer},
+				TerminationDate:        maturityDate.Format(FPML_DATE_FORMAT),
+				Notional:               notional,
+			},
+		},
+		CompressionActivity: compressionActivity,
+	}
+	message.Accounts = []fpmlAccount{
+		{ID: FPML_ACCOUNT_ID, AccountID: proposal.Book},
+	}
+	return message, nil
+}
+
+func createFpMLTradeIdentifier(proposal *Proposal) fpmlTradeIdentifier {
+	return fpmlTradeIdentifier{
+		PartyReference: fpmlReference{Href: FPML_PARTY_ID},
+		TradeIDs:       createFpMLTradeIDs(proposal),
+	}
+}
+
+func createFpMLTradeIDs(proposal *Proposal) []fpmlTradeID {
+	return []fpmlTradeID{
+		{Scheme: FPML_TRADE_ID_SCHEME, Value: proposal.TradeID},
+		{Scheme: FPML_CLEARING_TRADE_ID_SCHEME, Value: proposal.CCPTradeID},
+	}
+}
+
+func (store *RunStore) GetRunProposalsFpML(c *gin.Context) {
+	resp, ok := store.loadRequestedRun(c)
+	if !ok {
+		return
+	}
+
+	runSummary, err := store.LoadRunSummary(resp.RequestID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, api.CompressTradesResp{
+			RequestID: resp.RequestID,
+			Error:     fmt.Sprintf("Error in LoadRunSummary due to: %s", err.Error()),
+		})
+		return
+	}
+	createdAt, _ := time.Parse(time.RFC3339, runSummary.CreatedAt)
+
+	result, err := ParseRunResult(resp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, api.CompressTradesResp{
+			RequestID: resp.RequestID,
+			Error:     fmt.Sprintf("Error in ParseRunResult due to: %s", err.Error()),
+		})
+		return
+	}
+
+	party := c.Param("party")
+	proposals, ok := result.PartyToProposals[party]
+	if !ok {
+		c.JSON(http.StatusNotFound, api.CompressTradesResp{
+			RequestID: resp.RequestID,
+			Error:     fmt.Sprintf("run has no proposals for party %s", party),
+		})
+		return
+	}
+
+	files, err := createFpMLFiles(resp.RequestID, createdAt, proposals)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, api.CompressTradesResp{
+			RequestID: resp.RequestID,
+			Error:     fmt.Sprintf("Error in createFpMLFiles due to: %s", err.Error()),
+		})
+		return
+	}
+
+	streamAttachment(c, resp.RequestID, "application/zip", fmt.Sprintf("%s_%s_fpml.zip", resp.RequestID, url.PathEscape(party)),
+		"WriteProposalsFpML", func(w io.Writer) error {
+			return writeFpMLFiles(w, files)
+		})
+}
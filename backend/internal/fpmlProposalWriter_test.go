@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"github.com/zytan787/code-to-connect-2021/internal/toolkit"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestProposal(t *testing.T, tradeID string, payOrReceive string, notional string, action ActionType) *Proposal {
+	t.Helper()
+
+	parsedNotional, err := toolkit.ParseDecimal(notional)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Proposal{
+		Party:                "A",
+		Book:                 "B1",
+		TradeID:              tradeID,
+		PayOrReceive:         payOrReceive,
+		Currency:             "USD",
+		MaturityDate:         "2030/06/14",
+		AdjustedMaturityDate: "2030/06/14",
+		Cpty:                 "CCP",
+		CCPTradeID:           "C" + tradeID,
+		Notional:             parsedNotional,
+		Action:               action,
+	}
+}
+
+func TestWriteProposalsFpML(t *testing.T) {
+	proposals := []*Proposal{
+		newTestProposal(t, "T1", "P", "100.50", CANCEL),
+		newTestProposal(t, "T2", "R", "40", CANCEL),
+		newTestProposal(t, "N1", "P", "60.50", ADD),
+	}
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var buffer bytes.Buffer
+	if err := WriteProposalsFpML(&buffer, "run", createdAt, proposals); err != nil {
+		t.Fatal(err)
+	}
+	zipReader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedFileNames := []string{"0001_CXL_T1.xml", "0002_CXL_T2.xml", "0003_ADD_N1.xml"}
+	if len(zipReader.File) != len(expectedFileNames) {
+		t.Fatalf("expected %d files, got %d", len(expectedFileNames), len(zipReader.File))
+	}
+	for i, file := range zipReader.File {
+		if file.Name != expectedFileNames[i] {
+			t.Errorf("expected file %s, got %s", expectedFileNames[i], file.Name)
+		}
+	}
+
+	files, err := createFpMLFiles("run", createdAt, proposals)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	termination := files[0].message.Termination
+	if termination == nil {
+		t.Fatal("expected a termination for a CXL")
+	}
+	if termination.ChangeInNotionalAmount.Amount != "100.50" || termination.OutstandingNotionalAmount.Amount != "0.00" {
+		t.Errorf("expected 100.50 terminated and 0.00 outstanding, got %+v and %+v",
+			termination.ChangeInNotionalAmount, termination.OutstandingNotionalAmount)
+	}
+	if len(termination.CompressionActivity.ReplacementTradeIdentifiers) != 1 ||
+		termination.CompressionActivity.ReplacementTradeIdentifiers[0].TradeIDs[0].Value != "N1" {
+		t.Errorf("expected the termination to be replaced by N1, got %+v", termination.CompressionActivity)
+	}
+
+	trade := files[2].message.Trade
+	if trade == nil {
+		t.Fatal("expected a new trade for an ADD")
+	}
+	if len(trade.SwapStreams) != 2 {
+		t.Fatalf("expected two legs, got %+v", trade.SwapStreams)
+	}
+	for i, payer := range []string{FPML_PARTY_ID, FPML_CPTY_ID} {
+		swapStream := trade.SwapStreams[i]
+		if swapStream.PayerPartyReference.Href != payer || swapStream.ReceiverPartyReference.Href == payer {
+			t.Errorf("expected leg %d to be paid by %s, got %+v", i+1, payer, swapStream)
+		}
+		if swapStream.Notional.InitialValue != "60.50" || swapStream.TerminationDate != "2030-06-14" {
+			t.Errorf("expected leg %d to have notional 60.50 until 2030-06-14, got %+v", i+1, swapStream)
+		}
+	}
+	if len(trade.CompressionActivity.CompressedTradeIdentifiers) != 2 {
+		t.Errorf("expected the new trade to compress T1 and T2, got %+v", trade.CompressionActivity)
+	}
+}
+
+func TestReadWrittenProposalsFpML(t *testing.T) {
+	for _, payOrReceive := range []string{"P", "R"} {
+		t.Run(payOrReceive, func(t *testing.T) {
+			proposal := newTestProposal(t, "N1", payOrReceive, "60.50", ADD)
+
+			var buffer bytes.Buffer
+			if err := WriteProposalsFpML(&buffer, "run", time.Now(), []*Proposal{proposal}); err != nil {
+				t.Fatal(err)
+			}
+			zipReader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader, err := zipReader.File[0].Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := ioutil.ReadAll(reader)
+			reader.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var rawTrades []*RawTrade
+			err = readFpMLRawTrades("N1.xml", bytes.NewReader(content), func(rawTrade *RawTrade) {
+				rawTrades = append(rawTrades, rawTrade)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rawTrades) != 1 {
+				t.Fatalf("expected one trade, got %d", len(rawTrades))
+			}
+
+			expected := RawTrade{
+				Party:        "A",
+				Book:         "B1",
+				TradeID:      "N1",
+				PayOrReceive: payOrReceive,
+				Currency:     "USD",
+				MaturityDate: "2030-06-14",
+				Cpty:         "CCP",
+				CCPTradeID:   "CN1",
+				Notional:     "60.50",
+				RowNumber:    1,
+			}
+			if !reflect.DeepEqual(*rawTrades[0], expected) {
+				t.Errorf("expected %+v, got %+v", expected, *rawTrades[0])
+			}
+		})
+	}
+}
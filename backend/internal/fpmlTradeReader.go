@@ -0,0 +1,269 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const FPML_EXTENSION = ".xml"
+const FPML_PEEK_SIZE = 512
+const FPML_CLEARING_SCHEME = "clearing"
+
+var zeroFraction = regexp.MustCompile(`^(\d+)\.0*$`)
+
+type fpmlReference struct {
+	Href string `xml:"href,attr"`
+}
+
+type fpmlParty struct {
+	ID        string `xml:"id,attr"`
+	PartyID   string `xml:"partyId"`
+	PartyName string `xml:"partyName,omitempty"`
+}
+
+type fpmlAccount struct {
+	ID        string `xml:"id,attr"`
+	AccountID string `xml:"accountId"`
+}
+
+type fpmlTradeID struct {
+	Scheme string `xml:"tradeIdScheme,attr,omitempty"`
+	Value  string `xml:",chardata"`
+}
+
+type fpmlPartyTradeIdentifier struct {
+	PartyReference   fpmlReference `xml:"partyReference"`
+	AccountReference fpmlReference `xml:"accountReference"`
+	TradeIDs         []fpmlTradeID `xml:"tradeId"`
+}
+
+type fpmlNotionalStepSchedule struct {
+	InitialValue string `xml:"initialValue"`
+	Currency     string `xml:"currency"`
+}
+
+type fpmlSwapStream struct {
+	PayerPartyReference    fpmlReference            `xml:"payerPartyReference"`
+	ReceiverPartyReference fpmlReference            `xml:"receiverPartyReference"`
+	TerminationDate        string                   `xml:"calculationPeriodDates>terminationDate>unadjustedDate"`
+	Notional               fpmlNotionalStepSchedule `xml:"calculationPeriodAmount>calculation>notionalSchedule>notionalStepSchedule"`
+	FixedRateSchedule      *struct{}                `xml:"calculationPeriodAmount>calculation>fixedRateSchedule"`
+}
+
+type fpmlSwap struct {
+	SwapStreams []fpmlSwapStream `xml:"swapStream"`
+}
+
+type fpmlTrade struct {
+	PartyTradeIdentifiers []fpmlPartyTradeIdentifier `xml:"tradeHeader>partyTradeIdentifier"`
+	Swap                  *fpmlSwap                  `xml:"swap"`
+}
+
+type fpmlDocument struct {
+	onBehalfOf string
+	trades     []*fpmlTrade
+	parties    map[string]*fpmlParty
+	accounts   map[string]*fpmlAccount
+}
+
+func isFpMLFile(fileName string, in *bufio.Reader) bool {
+	if strings.EqualFold(filepath.Ext(fileName), FPML_EXTENSION) {
+		return true
+	}
+	start, _ := in.Peek(FPML_PEEK_SIZE)
+	start = bytes.TrimPrefix(start, []byte("\xef\xbb\xbf"))
+	start = bytes.TrimLeft(start, " \t\r\n")
+	return bytes.HasPrefix(start, []byte("<"))
+}
+
+// readFpMLRawTrades reads every swap trade of an FpML document. A document or trade that cannot be parsed
+// becomes a RawTrade with a ParseError, so that it is excluded instead of failing the whole request.
+func readFpMLRawTrades(fileName string, in io.Reader, onRawTrade func(rawTrade *RawTrade)) error {
+	content, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	document, err := decodeFpMLDocument(content)
+	if err != nil {
+		onRawTrade(&RawTrade{
+			ParseError: fmt.Sprintf("unable to parse FpML document %s due to: %s", filepath.Base(fileName), err.Error()),
+		})
+		return nil
+	}
+
+	for i, trade := range document.trades {
+		rawTrade := document.getRawTrade(fileName, i+1, trade)
+		rawTrade.RowNumber = i + 1
+		onRawTrade(rawTrade)
+	}
+	return nil
+}
+
+func decodeFpMLDocument(content []byte) (*fpmlDocument, error) {
+	document := &fpmlDocument{
+		parties:  make(map[string]*fpmlParty),
+		accounts: make(map[string]*fpmlAccount),
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "trade":
+			trade := &fpmlTrade{}
+			if err = decoder.DecodeElement(trade, &start); err != nil {
+				return nil, err
+			}
+			document.trades = append(document.trades, trade)
+		case "party":
+			party := &fpmlParty{}
+			if err = decoder.DecodeElement(party, &start); err != nil {
+				return nil, err
+			}
+			document.parties[party.ID] = party
+		case "account":
+			account := &fpmlAccount{}
+			if err = decoder.DecodeElement(account, &start); err != nil {
+				return nil, err
+			}
+			document.accounts[account.ID] = account
+		case "onBehalfOf":
+			onBehalfOf := &struct {
+				PartyReference fpmlReference `xml:"partyReference"`
+			}{}
+			if err = decoder.DecodeElement(onBehalfOf, &start); err != nil {
+				return nil, err
+			}
+			document.onBehalfOf = onBehalfOf.PartyReference.Href
+		}
+	}
+
+	if len(document.trades) == 0 {
+		return nil, fmt.Errorf("document has no trade")
+	}
+	return document, nil
+}
+
+// getRawTrade maps a swap to a RawTrade from the point of view of the submitting party: the party the message
+// is sent on behalf of, or else the first party with a trade ID that pays or receives the swap. The pay/receive
+// direction, currency, notional and maturity date are those of the fixed leg, or of the first leg when no leg
+// has a fixed rate.
+func (document *fpmlDocument) getRawTrade(fileName string, tradeNumber int, trade *fpmlTrade) *RawTrade {
+	rawTrade := &RawTrade{}
+
+	var swapStream *fpmlSwapStream
+	if trade.Swap != nil && len(trade.Swap.SwapStreams) > 0 {
+		swapStream = &trade.Swap.SwapStreams[0]
+		for i := range trade.Swap.SwapStreams {
+			if trade.Swap.SwapStreams[i].FixedRateSchedule != nil {
+				swapStream = &trade.Swap.SwapStreams[i]
+				break
+			}
+		}
+	}
+
+	var partyIdentifier *fpmlPartyTradeIdentifier
+	for i := range trade.PartyTradeIdentifiers {
+		href := trade.PartyTradeIdentifiers[i].PartyReference.Href
+		if len(document.onBehalfOf) > 0 && href != document.onBehalfOf {
+			continue
+		}
+		if len(document.onBehalfOf) == 0 && swapStream != nil &&
+			href != swapStream.PayerPartyReference.Href && href != swapStream.ReceiverPartyReference.Href {
+			continue
+		}
+		partyIdentifier = &trade.PartyTradeIdentifiers[i]
+		break
+	}
+	if partyIdentifier == nil {
+		rawTrade.ParseError = fmt.Sprintf("trade %d of FpML document %s has no trade ID for the submitting party",
+			tradeNumber, filepath.Base(fileName))
+		return rawTrade
+	}
+
+	partyHref := partyIdentifier.PartyReference.Href
+	rawTrade.Party = document.getPartyName(partyHref)
+	if len(partyIdentifier.TradeIDs) > 0 {
+		rawTrade.TradeID = strings.TrimSpace(partyIdentifier.TradeIDs[0].Value)
+	}
+	if account, ok := document.accounts[partyIdentifier.AccountReference.Href]; ok {
+		rawTrade.Book = strings.TrimSpace(account.AccountID)
+	}
+
+	if swapStream == nil {
+		rawTrade.ParseError = fmt.Sprintf("trade %s of FpML document %s is not an interest rate swap",
+			rawTrade.TradeID, filepath.Base(fileName))
+		return rawTrade
+	}
+
+	cptyHref := swapStream.ReceiverPartyReference.Href
+	if swapStream.PayerPartyReference.Href == partyHref {
+		rawTrade.PayOrReceive = "P"
+	} else if swapStream.ReceiverPartyReference.Href == partyHref {
+		rawTrade.PayOrReceive = "R"
+		cptyHref = swapStream.PayerPartyReference.Href
+	} else {
+		rawTrade.ParseError = fmt.Sprintf("trade %s of FpML document %s is neither paid nor received by party %s",
+			rawTrade.TradeID, filepath.Base(fileName), rawTrade.Party)
+		return rawTrade
+	}
+
+	rawTrade.Cpty = document.getPartyName(cptyHref)
+	rawTrade.Currency = strings.TrimSpace(swapStream.Notional.Currency)
+	rawTrade.MaturityDate = strings.TrimSpace(swapStream.TerminationDate)
+	rawTrade.Notional = zeroFraction.ReplaceAllString(strings.TrimSpace(swapStream.Notional.InitialValue), "$1")
+	rawTrade.CCPTradeID = getFpMLClearingID(trade, partyHref, cptyHref)
+
+	return rawTrade
+}
+
+func (document *fpmlDocument) getPartyName(href string) string {
+	party, ok := document.parties[href]
+	if !ok {
+		return ""
+	}
+	if len(strings.TrimSpace(party.PartyID)) > 0 {
+		return strings.TrimSpace(party.PartyID)
+	}
+	return strings.TrimSpace(party.PartyName)
+}
+
+// getFpMLClearingID returns the trade ID with a clearing scheme, or else the trade ID given by a party that is
+// neither the party nor its counterparty, which is the clearing house
+func getFpMLClearingID(trade *fpmlTrade, partyHref string, cptyHref string) string {
+	for _, partyIdentifier := range trade.PartyTradeIdentifiers {
+		for _, tradeID := range partyIdentifier.TradeIDs {
+			if strings.Contains(strings.ToLower(tradeID.Scheme), FPML_CLEARING_SCHEME) {
+				return strings.TrimSpace(tradeID.Value)
+			}
+		}
+	}
+
+	for _, partyIdentifier := range trade.PartyTradeIdentifiers {
+		href := partyIdentifier.PartyReference.Href
+		if href != partyHref && href != cptyHref && len(partyIdentifier.TradeIDs) > 0 {
+			return strings.TrimSpace(partyIdentifier.TradeIDs[0].Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const TEST_FPML_PARTIES = `
+  <party id="party1"><partyId>A</partyId></party>
+  <party id="party2"><partyId>D</partyId></party>
+  <party id="party3"><partyName>LCH</partyName></party>
+  <account id="account1"><accountId>1BKA</accountId></account>`
+
+const TEST_FPML_SWAP = `
+  <trade>
+    <tradeHeader>
+      <partyTradeIdentifier>
+        <partyReference href="party1"/>
+        <accountReference href="account1"/>
+        <tradeId>A1</tradeId>
+      </partyTradeIdentifier>
+      <partyTradeIdentifier>
+        <partyReference href="party2"/>
+        <tradeId>D1</tradeId>
+      </partyTradeIdentifier>
+      <partyTradeIdentifier>
+        <partyReference href="party3"/>
+        <tradeId>CCP1</tradeId>
+      </partyTradeIdentifier>
+    </tradeHeader>
+    <swap>
+      <swapStream>
+        <payerPartyReference href="party1"/>
+        <receiverPartyReference href="party2"/>
+        <calculationPeriodDates><terminationDate><unadjustedDate>2030-06-14</unadjustedDate></terminationDate></calculationPeriodDates>
+        <calculationPeriodAmount><calculation>
+          <notionalSchedule><notionalStepSchedule><initialValue>100000.00</initialValue><currency>EUR</currency></notionalStepSchedule></notionalSchedule>
+          <floatingRateCalculation/>
+        </calculation></calculationPeriodAmount>
+      </swapStream>
+      <swapStream>
+        <payerPartyReference href="party2"/>
+        <receiverPartyReference href="party1"/>
+        <calculationPeriodDates><terminationDate><unadjustedDate>2030-06-14</unadjustedDate></terminationDate></calculationPeriodDates>
+        <calculationPeriodAmount><calculation>
+          <notionalSchedule><notionalStepSchedule><initialValue>100000.00</initialValue><currency>EUR</currency></notionalStepSchedule></notionalSchedule>
+          <fixedRateSchedule><initialValue>0.01</initialValue></fixedRateSchedule>
+        </calculation></calculationPeriodAmount>
+      </swapStream>
+    </swap>
+  </trade>`
+
+func readTestFpMLRawTrades(t *testing.T, content string) []*RawTrade {
+	t.Helper()
+
+	var rawTrades []*RawTrade
+	err := readFpMLRawTrades("trades.xml", strings.NewReader(content), func(rawTrade *RawTrade) {
+		rawTrades = append(rawTrades, rawTrade)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rawTrades
+}
+
+func TestReadFpMLRawTrades(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []RawTrade
+	}{
+		{"fixed leg received by the first party", "<dataDocument>" + TEST_FPML_SWAP + TEST_FPML_PARTIES + "</dataDocument>",
+			[]RawTrade{{Party: "A", Book: "1BKA", TradeID: "A1", PayOrReceive: "R", Currency: "EUR",
+				MaturityDate: "2030-06-14", Cpty: "D", CCPTradeID: "CCP1", Notional: "100000", RowNumber: 1}}},
+		{"fixed leg paid by the party sent on behalf of",
+			`<requestClearing><header><onBehalfOf><partyReference href="party2"/></onBehalfOf></header>` +
+				TEST_FPML_SWAP + TEST_FPML_PARTIES + "</requestClearing>",
+			[]RawTrade{{Party: "D", TradeID: "D1", PayOrReceive: "P", Currency: "EUR",
+				MaturityDate: "2030-06-14", Cpty: "A", CCPTradeID: "CCP1", Notional: "100000", RowNumber: 1}}},
+		{"clearing scheme", "<dataDocument>" +
+			strings.Replace(TEST_FPML_SWAP, "<tradeId>D1</tradeId>",
+				`<tradeId>D1</tradeId><tradeId tradeIdScheme="http://www.lchclearnet.com/clearing-trade-id">C9</tradeId>`, 1) +
+			TEST_FPML_PARTIES + "</dataDocument>",
+			[]RawTrade{{Party: "A", Book: "1BKA", TradeID: "A1", PayOrReceive: "R", Currency: "EUR",
+				MaturityDate: "2030-06-14", Cpty: "D", CCPTradeID: "C9", Notional: "100000", RowNumber: 1}}},
+		{"trade that is not a swap", "<dataDocument>" + TEST_FPML_SWAP + `
+  <trade>
+    <tradeHeader><partyTradeIdentifier><partyReference href="party1"/><tradeId>A2</tradeId></partyTradeIdentifier></tradeHeader>
+    <fra/>
+  </trade>` + TEST_FPML_PARTIES + "</dataDocument>",
+			[]RawTrade{{Party: "A", Book: "1BKA", TradeID: "A1", PayOrReceive: "R", Currency: "EUR",
+				MaturityDate: "2030-06-14", Cpty: "D", CCPTradeID: "CCP1", Notional: "100000", RowNumber: 1},
+				{Party: "A", TradeID: "A2", RowNumber: 2,
+					ParseError: "trade A2 of FpML document trades.xml is not an interest rate swap"}}},
+		{"trade without a trade ID of the submitting party",
+			`<requestClearing><header><onBehalfOf><partyReference href="party4"/></onBehalfOf></header>` +
+				TEST_FPML_SWAP + TEST_FPML_PARTIES + "</requestClearing>",
+			[]RawTrade{{RowNumber: 1,
+				ParseError: "trade 1 of FpML document trades.xml has no trade ID for the submitting party"}}},
+		{"document without trades", "<dataDocument>" + TEST_FPML_PARTIES + "</dataDocument>",
+			[]RawTrade{{ParseError: "unable to parse FpML document trades.xml due to: document has no trade"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rawTrades := readTestFpMLRawTrades(t, test.content)
+			if len(rawTrades) != len(test.expected) {
+				t.Fatalf("expected %d trades, got %d", len(test.expected), len(rawTrades))
+			}
+			for i, rawTrade := range rawTrades {
+				if !reflect.DeepEqual(*rawTrade, test.expected[i]) {
+					t.Errorf("expected %+v, got %+v", test.expected[i], *rawTrade)
+				}
+			}
+		})
+	}
+}
+
+func TestReadInvalidFpMLDocument(t *testing.T) {
+	rawTrades := readTestFpMLRawTrades(t, "<dataDocument>"+TEST_FPML_SWAP)
+	if len(rawTrades) != 1 || !strings.HasPrefix(rawTrades[0].ParseError, "unable to parse FpML document trades.xml due to:") {
+		t.Errorf("expected the invalid document to become one trade with a parse error, got %+v", rawTrades)
+	}
+}
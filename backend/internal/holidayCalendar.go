@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BusinessDayConvention moves a maturity date that is not a business day of its currency
+type BusinessDayConvention string
+
+const (
+	// compare and bucket the maturity dates as they are written
+	BUSINESS_DAY_CONVENTION_NONE BusinessDayConvention = "none"
+	// move to the next business day
+	BUSINESS_DAY_CONVENTION_FOLLOWING BusinessDayConvention = "following"
+	// move to the next business day, unless it is in the next month, then move to the previous business day
+	BUSINESS_DAY_CONVENTION_MODIFIED_FOLLOWING BusinessDayConvention = "modified_following"
+)
+
+// a calendar leaving no business day within a year is taken as wrong rather than searched through
+const MAX_DAYS_WITHOUT_BUSINESS_DAY = 366
+
+// HolidayCalendar holds the holidays of a currency by date in DATE_FORMAT, weekends are never business days
+type HolidayCalendar map[string]bool
+
+// HolidayCalendars holds a HolidayCalendar per currency, currencies without a calendar only skip weekends
+type HolidayCalendars map[string]HolidayCalendar
+
+func ParseBusinessDayConvention(convention string) (BusinessDayConvention, error) {
+	switch BusinessDayConvention(strings.ToLower(strings.TrimSpace(convention))) {
+	case "", BUSINESS_DAY_CONVENTION_NONE:
+		return BUSINESS_DAY_CONVENTION_NONE, nil
+	case BUSINESS_DAY_CONVENTION_FOLLOWING:
+		return BUSINESS_DAY_CONVENTION_FOLLOWING, nil
+	case BUSINESS_DAY_CONVENTION_MODIFIED_FOLLOWING:
+		return BUSINESS_DAY_CONVENTION_MODIFIED_FOLLOWING, nil
+	}
+	return "", fmt.Errorf("business_day_convention %s is neither '%s', '%s' or '%s'", convention,
+		BUSINESS_DAY_CONVENTION_NONE, BUSINESS_DAY_CONVENTION_FOLLOWING, BUSINESS_DAY_CONVENTION_MODIFIED_FOLLOWING)
+}
+
+// LoadHolidayCalendars reads one file per currency from a directory, like USD.csv or EUR.txt. Every line holds a
+// holiday written year first, optionally followed by a comma and its name, e.g. "2025/12/25,Christmas Day".
+// Empty lines, lines starting with # and a header line starting with Date, before any holiday, are skipped.
+func LoadHolidayCalendars(dir string) (HolidayCalendars, error) {
+	fileInfos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read holiday calendars %s due to: %s", dir, err.Error())
+	}
+
+	calendars := make(HolidayCalendars)
+	for _, fileInfo := range fileInfos {
+		if fileInfo.IsDir() || strings.HasPrefix(fileInfo.Name(), ".") {
+			continue
+		}
+
+		currency := NormalizeCurrency(strings.TrimSuffix(fileInfo.Name(), filepath.Ext(fileInfo.Name())))
+		if !IsISO4217Currency(currency) {
+			return nil, fmt.Errorf("holiday calendar %s is not named after an ISO 4217 currency code", fileInfo.Name())
+		}
+		if _, ok := calendars[currency]; ok {
+			return nil, fmt.Errorf("currency %s has more than one holiday calendar", currency)
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, fileInfo.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read holiday calendar %s due to: %s", fileInfo.Name(), err.Error())
+		}
+		calendars[currency], err = parseHolidayCalendar(content)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse holiday calendar %s due to: %s", fileInfo.Name(), err.Error())
+		}
+	}
+	return calendars, nil
+}
+
+func parseHolidayCalendar(content []byte) (HolidayCalendar, error) {
+	calendar := make(HolidayCalendar)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNumber := 0
+	isFirstLine := true
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		value := strings.TrimSpace(strings.SplitN(line, ",", 2)[0])
+		if isFirstLine && strings.EqualFold(value, "Date") {
+			isFirstLine = false
+			continue
+		}
+		isFirstLine = false
+
+		match := yearFirstDatePattern.FindStringSubmatch(value)
+		if match == nil || len(match[1]) == 0 {
+			return nil, fmt.Errorf("line %d: %s is not a date like YYYY/MM/DD", lineNumber, value)
+		}
+		holiday, err := newDate(match[1], match[2], match[3], fmt.Errorf("line %d: %s is not a valid date", lineNumber, value))
+		if err != nil {
+			return nil, err
+		}
+		calendar[holiday.Format(DATE_FORMAT)] = true
+	}
+	return calendar, scanner.Err()
+}
+
+func (calendars HolidayCalendars) isBusinessDay(date time.Time, currency string) bool {
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return false
+	}
+	return !calendars[currency][date.Format(DATE_FORMAT)]
+}
+
+// addBusinessDays returns the date that is the given number of business days of the currency after date
+func (calendars HolidayCalendars) addBusinessDays(date time.Time, businessDays int, currency string) (time.Time, error) {
+	var err error
+	for ; businessDays > 0; businessDays-- {
+		if date, err = calendars.nextBusinessDay(date, 1, currency); err != nil {
+			return date, err
+		}
+	}
+	return date, nil
+}
+
+// adjustDate returns the business day of the currency that the convention moves date to
+func (calendars HolidayCalendars) adjustDate(date time.Time, currency string, convention BusinessDayConvention) (time.Time, error) {
+	if convention != BUSINESS_DAY_CONVENTION_FOLLOWING && convention != BUSINESS_DAY_CONVENTION_MODIFIED_FOLLOWING {
+		return date, nil
+	}
+	if calendars.isBusinessDay(date, currency) {
+		return date, nil
+	}
+
+	following, err := calendars.nextBusinessDay(date, 1, currency)
+	if err != nil {
+		return date, err
+	}
+	if convention == BUSINESS_DAY_CONVENTION_MODIFIED_FOLLOWING && following.Month() != date.Month() {
+		return calendars.nextBusinessDay(date, -1, currency)
+	}
+	return following, nil
+}
+
+// nextBusinessDay moves date one day at a time in the direction of step, 1 or -1, until it is a business day of
+// the currency, and fails after MAX_DAYS_WITHOUT_BUSINESS_DAY days
+func (calendars HolidayCalendars) nextBusinessDay(date time.Time, step int, currency string) (time.Time, error) {
+	nextDate := date
+	for days := 0; days < MAX_DAYS_WITHOUT_BUSINESS_DAY; days++ {
+		nextDate = nextDate.AddDate(0, 0, step)
+		if calendars.isBusinessDay(nextDate, currency) {
+			return nextDate, nil
+		}
+	}
+	return date, fmt.Errorf("holiday calendar of %s has no business day within %d days of %s",
+		currency, MAX_DAYS_WITHOUT_BUSINESS_DAY, date.Format(DATE_FORMAT))
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var testHolidayCalendars = HolidayCalendars{
+	"USD": {"2025/05/30": true, "2025/11/27": true, "2025/11/28": true, "2025/12/31": true},
+}
+
+func mustParseDate(t *testing.T, value string) time.Time {
+	t.Helper()
+
+	date, err := time.Parse(DATE_FORMAT, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return date
+}
+
+func TestAdjustDate(t *testing.T) {
+	tests := []struct {
+		name       string
+		date       string
+		currency   string
+		convention BusinessDayConvention
+		expected   string
+	}{
+		{"business day", "2025/05/29", "USD", BUSINESS_DAY_CONVENTION_MODIFIED_FOLLOWING, "2025/05/29"},
+		{"weekend without convention", "2025/05/31", "USD", BUSINESS_DAY_CONVENTION_NONE, "2025/05/31"},
+		{"weekend in the middle of the month", "2025/05/17", "EUR", BUSINESS_DAY_CONVENTION_MODIFIED_FOLLOWING, "2025/05/19"},
+		{"weekend at month end with following", "2025/05/31", "EUR", BUSINESS_DAY_CONVENTION_FOLLOWING, "2025/06/02"},
+		{"weekend at month end with modified following", "2025/05/31", "EUR", BUSINESS_DAY_CONVENTION_MODIFIED_FOLLOWING, "2025/05/30"},
+		{"weekend after a holiday at month end", "2025/05/31", "USD", BUSINESS_DAY_CONVENTION_MODIFIED_FOLLOWING, "2025/05/29"},
+		{"weekend after two holidays at month end", "2025/11/30", "USD", BUSINESS_DAY_CONVENTION_MODIFIED_FOLLOWING, "2025/11/26"},
+		{"holiday at year end with following", "2025/12/31", "USD", BUSINESS_DAY_CONVENTION_FOLLOWING, "2026/01/01"},
+		{"holiday at year end with modified following", "2025/12/31", "USD", BUSINESS_DAY_CONVENTION_MODIFIED_FOLLOWING, "2025/12/30"},
+		{"holiday of another currency", "2025/12/31", "EUR", BUSINESS_DAY_CONVENTION_MODIFIED_FOLLOWING, "2025/12/31"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			adjustedDate, err := testHolidayCalendars.adjustDate(mustParseDate(t, test.date), test.currency, test.convention)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if adjustedDate.Format(DATE_FORMAT) != test.expected {
+				t.Errorf("expected %s in %s to be adjusted to %s, got %s", test.date, test.currency, test.expected, adjustedDate.Format(DATE_FORMAT))
+			}
+		})
+	}
+}
+
+func TestAddBusinessDays(t *testing.T) {
+	tests := []struct {
+		name         string
+		date         string
+		businessDays int
+		currency     string
+		expected     string
+	}{
+		{"no business days", "2025/05/31", 0, "USD", "2025/05/31"},
+		{"over a weekend", "2025/05/30", 1, "EUR", "2025/06/02"},
+		{"over a weekend into the next month", "2025/05/30", 2, "EUR", "2025/06/03"},
+		{"over holidays and a weekend", "2025/11/26", 2, "USD", "2025/12/02"},
+		{"from a holiday", "2025/05/30", 1, "USD", "2025/06/02"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			date, err := testHolidayCalendars.addBusinessDays(mustParseDate(t, test.date), test.businessDays, test.currency)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if date.Format(DATE_FORMAT) != test.expected {
+				t.Errorf("expected %d business days of %s after %s to be %s, got %s", test.businessDays, test.currency, test.date, test.expected, date.Format(DATE_FORMAT))
+			}
+		})
+	}
+}
+
+func TestCalendarWithoutBusinessDays(t *testing.T) {
+	calendar := make(HolidayCalendar)
+	for date := mustParseDate(t, "2025/01/01"); date.Year() < 2027; date = date.AddDate(0, 0, 1) {
+		calendar[date.Format(DATE_FORMAT)] = true
+	}
+	calendars := HolidayCalendars{"USD": calendar}
+
+	if _, err := calendars.adjustDate(mustParseDate(t, "2025/06/01"), "USD", BUSINESS_DAY_CONVENTION_MODIFIED_FOLLOWING); err == nil ||
+		!strings.Contains(err.Error(), "no business day") {
+		t.Errorf("expected adjustDate to fail without business days, got %v", err)
+	}
+	if _, err := calendars.addBusinessDays(mustParseDate(t, "2025/06/01"), 1, "USD"); err == nil ||
+		!strings.Contains(err.Error(), "no business day") {
+		t.Errorf("expected addBusinessDays to fail without business days, got %v", err)
+	}
+}
+
+func TestParseHolidayCalendar(t *testing.T) {
+	content := "# USD holidays\n\nDate,Name\n2025/05/26,Memorial Day\n2025/07/04\n"
+	calendar, err := parseHolidayCalendar([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calendar) != 2 || !calendar["2025/05/26"] || !calendar["2025/07/04"] {
+		t.Errorf("expected the holidays 2025/05/26 and 2025/07/04, got %v", calendar)
+	}
+
+	if _, err = parseHolidayCalendar([]byte("2025/05/26\nDate\n")); err == nil {
+		t.Errorf("expected a Date line after a holiday to be invalid")
+	}
+}
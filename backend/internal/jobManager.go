@@ -0,0 +1,298 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/zytan787/code-to-connect-2021/api"
+	"github.com/zytan787/code-to-connect-2021/internal/toolkit"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const JOB_RETENTION = 24 * time.Hour
+
+type JobStage string
+
+const (
+	JOB_QUEUED           JobStage = "Queued"
+	JOB_LOADING          JobStage = "Loading"
+	JOB_COMPRESSION      JobStage = "Compression"
+	JOB_EVENT_GENERATION JobStage = "EventGeneration"
+	JOB_DATA_CHECK       JobStage = "DataCheck"
+	JOB_DONE             JobStage = "Done"
+	JOB_FAILED           JobStage = "Failed"
+)
+
+type Job struct {
+	RequestID   string
+	Stage       JobStage
+	SubmittedAt time.Time
+	FinishedAt  time.Time
+	Resp        *api.CompressTradesResp
+	StatusCode  int
+	result      *RunResult
+	inputFiles  []api.File
+	inputHash   string
+	options     RequestOptions
+}
+
+type JobManager struct {
+	mutex                 sync.RWMutex
+	jobs                  map[string]*Job
+	queue                 chan *Job
+	runStore              *RunStore
+	columnMappingProfiles ColumnMappingProfiles
+	allowedCurrencies     map[string]bool
+	holidayCalendars      HolidayCalendars
+}
+
+func NewJobManager(noOfWorkers int, queueSize int, runStore *RunStore, columnMappingProfiles ColumnMappingProfiles,
+	allowedCurrencies map[string]bool, holidayCalendars HolidayCalendars) *JobManager {
+	manager := &JobManager{
+		jobs:                  make(map[string]*Job),
+		queue:                 make(chan *Job, queueSize),
+		runStore:              runStore,
+		columnMappingProfiles: columnMappingProfiles,
+		allowedCurrencies:     allowedCurrencies,
+		holidayCalendars:      holidayCalendars,
+	}
+
+	for i := 0; i < noOfWorkers; i++ {
+		go manager.runWorker()
+	}
+
+	return manager
+}
+
+func (manager *JobManager) SubmitJob(c *gin.Context) {
+	var req api.CompressTradesReq
+	var resp api.JobStatus
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error = err.Error()
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+
+	if len(req.InputFiles) == 0 {
+		resp.Error = "input_files array has 0 element"
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+
+	options, err := parseRequestOptions(req)
+	if err != nil {
+		resp.Error = err.Error()
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+
+	if len(req.RequestID) <= 0 {
+		req.RequestID = toolkit.UniqueID()
+	}
+	resp.RequestID = req.RequestID
+
+	inputHash, err := manager.newMainHandler(options).hashInputFiles(req.InputFiles)
+	if err != nil {
+		resp.Error = err.Error()
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+
+	var storedResp *api.CompressTradesResp
+	if manager.runStore != nil {
+		storedResp, err = manager.runStore.FindRun(req.RequestID, inputHash)
+		if err == ErrRequestIDConflict {
+			resp.Error = fmt.Sprintf("request_id %s was already used with different input files or options", req.RequestID)
+			c.JSON(http.StatusConflict, resp)
+			return
+		}
+		if err != nil {
+			resp.Error = err.Error()
+			c.JSON(http.StatusInternalServerError, resp)
+			return
+		}
+	}
+
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.removeExpiredJobs()
+
+	// a failed job is replaced by the resubmitted one, only jobs that are running or done are returned again
+	if job, ok := manager.jobs[req.RequestID]; ok && job.Stage != JOB_FAILED {
+		if job.inputHash != inputHash {
+			resp.Error = fmt.Sprintf("request_id %s was already used with different input files or options", req.RequestID)
+			c.JSON(http.StatusConflict, resp)
+			return
+		}
+		c.JSON(http.StatusOK, manager.getJobStatusLocked(job))
+		return
+	}
+
+	job := &Job{
+		RequestID:   req.RequestID,
+		Stage:       JOB_QUEUED,
+		SubmittedAt: time.Now(),
+		inputFiles:  req.InputFiles,
+		inputHash:   inputHash,
+		options:     options,
+	}
+
+	if storedResp != nil {
+		job.Stage = JOB_DONE
+		job.Resp = storedResp
+		job.StatusCode = http.StatusOK
+		job.FinishedAt = job.SubmittedAt
+		job.inputFiles = nil
+		manager.jobs[job.RequestID] = job
+		c.JSON(http.StatusOK, manager.getJobStatusLocked(job))
+		return
+	}
+
+	select {
+	case manager.queue <- job:
+	default:
+		resp.Error = "job queue is full, please try again later"
+		c.JSON(http.StatusServiceUnavailable, resp)
+		return
+	}
+
+	manager.jobs[job.RequestID] = job
+	resp.Stage = string(job.Stage)
+	c.JSON(http.StatusAccepted, resp)
+}
+
+func (manager *JobManager) GetJobStatus(c *gin.Context) {
+	job, ok := manager.getJob(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, api.JobStatus{
+			RequestID: c.Param("id"),
+			Error:     "job not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, manager.getJobStatus(job))
+}
+
+func (manager *JobManager) GetJobResult(c *gin.Context) {
+	job, ok := manager.getJob(c.Param("id"))
+	if !ok {
+		WriteCompressTradesResp(c, http.StatusNotFound, &api.CompressTradesResp{
+			RequestID: c.Param("id"),
+			Error:     "job not found",
+		})
+		return
+	}
+
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	if job.Stage != JOB_DONE && job.Stage != JOB_FAILED {
+		c.JSON(http.StatusAccepted, api.JobStatus{
+			RequestID: job.RequestID,
+			Stage:     string(job.Stage),
+			Error:     fmt.Sprintf("job is still in stage %s", job.Stage),
+		})
+		return
+	}
+
+	WriteCompressTradesResult(c, job.StatusCode, job.Resp, job.result)
+}
+
+func (manager *JobManager) getJob(requestID string) (*Job, bool) {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	job, ok := manager.jobs[requestID]
+	return job, ok
+}
+
+func (manager *JobManager) getJobStatus(job *Job) api.JobStatus {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+
+	return manager.getJobStatusLocked(job)
+}
+
+func (manager *JobManager) getJobStatusLocked(job *Job) api.JobStatus {
+	jobStatus := api.JobStatus{
+		RequestID:   job.RequestID,
+		Stage:       string(job.Stage),
+		SubmittedAt: job.SubmittedAt.Format(time.RFC3339),
+	}
+	if !job.FinishedAt.IsZero() {
+		jobStatus.FinishedAt = job.FinishedAt.Format(time.RFC3339)
+	}
+	if job.Resp != nil {
+		jobStatus.Error = job.Resp.Error
+	}
+	return jobStatus
+}
+
+func (manager *JobManager) setJobStage(job *Job, stage JobStage) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	job.Stage = stage
+}
+
+func (manager *JobManager) removeExpiredJobs() {
+	for requestID, job := range manager.jobs {
+		if !job.FinishedAt.IsZero() && time.Since(job.FinishedAt) > JOB_RETENTION {
+			delete(manager.jobs, requestID)
+		}
+	}
+}
+
+func (manager *JobManager) runWorker() {
+	for job := range manager.queue {
+		manager.runJob(job)
+	}
+}
+
+func (manager *JobManager) newMainHandler(options RequestOptions) *MainHandler {
+	handler := NewMainHandler()
+	handler.RunStore = manager.runStore
+	handler.ColumnMappingProfiles = manager.columnMappingProfiles
+	handler.AllowedCurrencies = manager.allowedCurrencies
+	handler.HolidayCalendars = manager.holidayCalendars
+	handler.setRequestOptions(options)
+	return handler
+}
+
+func (manager *JobManager) runJob(job *Job) {
+	logger := logrus.WithFields(logrus.Fields{
+		"request_id": job.RequestID,
+	})
+
+	resp := &api.CompressTradesResp{
+		RequestID: job.RequestID,
+	}
+
+	handler := manager.newMainHandler(job.options)
+	handler.onStageChange = func(stage JobStage) {
+		manager.setJobStage(job, stage)
+	}
+
+	jobStart := time.Now()
+	statusCode := handler.runCompression(job.inputFiles, resp, logger)
+	logger.Infof("Done running job, took %s", time.Since(jobStart))
+
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	job.Resp = resp
+	job.StatusCode = statusCode
+	job.result = handler.runResult
+	job.FinishedAt = time.Now()
+	job.inputFiles = nil
+	if statusCode == http.StatusOK {
+		job.Stage = JOB_DONE
+	} else {
+		job.Stage = JOB_FAILED
+	}
+}
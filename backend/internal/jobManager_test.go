@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/zytan787/code-to-connect-2021/api"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveTestJobRequest(t *testing.T, manager *JobManager, method string, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var content []byte
+	if body != nil {
+		var err error
+		if content, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/jobs", manager.SubmitJob)
+	router.GET("/jobs/:id", manager.GetJobStatus)
+	router.GET("/jobs/:id/result", manager.GetJobResult)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, bytes.NewReader(content))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func submitTestJob(t *testing.T, manager *JobManager, requestID string, content string) (int, api.JobStatus) {
+	t.Helper()
+
+	recorder := serveTestJobRequest(t, manager, http.MethodPost, "/jobs", api.CompressTradesReq{
+		RequestID: requestID,
+		InputFiles: []api.File{{
+			FileName:    "input.csv",
+			FileContent: base64.StdEncoding.EncodeToString([]byte(content)),
+		}},
+	})
+
+	var jobStatus api.JobStatus
+	if err := json.Unmarshal(recorder.Body.Bytes(), &jobStatus); err != nil {
+		t.Fatalf("unable to decode response %s due to: %s", recorder.Body.String(), err.Error())
+	}
+	return recorder.Code, jobStatus
+}
+
+func getTestJobStatus(t *testing.T, manager *JobManager, requestID string) api.JobStatus {
+	t.Helper()
+
+	recorder := serveTestJobRequest(t, manager, http.MethodGet, "/jobs/"+requestID, nil)
+	var jobStatus api.JobStatus
+	if err := json.Unmarshal(recorder.Body.Bytes(), &jobStatus); err != nil {
+		t.Fatalf("unable to decode response %s due to: %s", recorder.Body.String(), err.Error())
+	}
+	return jobStatus
+}
+
+func TestJobQueueIsFull(t *testing.T) {
+	// without workers the queued job is never taken off the queue
+	manager := NewJobManager(0, 1, nil, nil, nil, nil)
+
+	if statusCode, jobStatus := submitTestJob(t, manager, "first", TEST_INPUT_FILE); statusCode != http.StatusAccepted {
+		t.Fatalf("expected the first job to be accepted, got %d: %s", statusCode, jobStatus.Error)
+	}
+	if statusCode, _ := submitTestJob(t, manager, "second", TEST_INPUT_FILE); statusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d with a full queue, got %d", http.StatusServiceUnavailable, statusCode)
+	}
+	if recorder := serveTestJobRequest(t, manager, http.MethodGet, "/jobs/second", nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("expected the rejected job not to be kept, got %d", recorder.Code)
+	}
+}
+
+func TestJobStages(t *testing.T) {
+	manager := NewJobManager(0, 2, nil, nil, nil, nil)
+
+	if statusCode, jobStatus := submitTestJob(t, manager, "run", TEST_INPUT_FILE); statusCode != http.StatusAccepted ||
+		jobStatus.Stage != string(JOB_QUEUED) {
+		t.Fatalf("expected the job to be accepted as %s, got %d %+v", JOB_QUEUED, statusCode, jobStatus)
+	}
+	if recorder := serveTestJobRequest(t, manager, http.MethodGet, "/jobs/run/result", nil); recorder.Code != http.StatusAccepted {
+		t.Errorf("expected status %d for the result of a queued job, got %d", http.StatusAccepted, recorder.Code)
+	}
+
+	var stages []JobStage
+	job := <-manager.queue
+	handler := manager.newMainHandler(job.options)
+	handler.onStageChange = func(stage JobStage) {
+		stages = append(stages, stage)
+	}
+	if statusCode := handler.runCompression(job.inputFiles, &api.CompressTradesResp{RequestID: job.RequestID},
+		logrus.WithFields(logrus.Fields{})); statusCode != http.StatusOK {
+		t.Fatalf("expected the job to run, got %d", statusCode)
+	}
+	expectedStages := []JobStage{JOB_LOADING, JOB_COMPRESSION, JOB_EVENT_GENERATION, JOB_DATA_CHECK}
+	if len(stages) != len(expectedStages) {
+		t.Fatalf("expected the stages %v, got %v", expectedStages, stages)
+	}
+	for i, stage := range stages {
+		if stage != expectedStages[i] {
+			t.Errorf("expected the stages %v, got %v", expectedStages, stages)
+			break
+		}
+	}
+
+	manager.runJob(job)
+	jobStatus := getTestJobStatus(t, manager, "run")
+	if jobStatus.Stage != string(JOB_DONE) || len(jobStatus.FinishedAt) == 0 {
+		t.Errorf("expected the job to be %s with a finish time, got %+v", JOB_DONE, jobStatus)
+	}
+
+	recorder := serveTestJobRequest(t, manager, http.MethodGet, "/jobs/run/result", nil)
+	var result api.CompressTradesResult
+	if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil || recorder.Code != http.StatusOK {
+		t.Fatalf("expected the result of the done job, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	if len(result.Proposals) != 2 {
+		t.Errorf("expected proposals for 2 parties, got %d", len(result.Proposals))
+	}
+
+	if statusCode, _ := submitTestJob(t, manager, "run", TEST_INPUT_FILE); statusCode != http.StatusOK {
+		t.Errorf("expected the done job to be returned for the same input, got %d", statusCode)
+	}
+	if statusCode, _ := submitTestJob(t, manager, "run", TEST_INPUT_FILE+"A,1BKA,A2,P,EUR,2022/12/31,D,CCP2,1\n"); statusCode != http.StatusConflict {
+		t.Errorf("expected status %d for different input, got %d", http.StatusConflict, statusCode)
+	}
+}
+
+func TestFailedJobIsReplaced(t *testing.T) {
+	manager := NewJobManager(0, 2, nil, nil, nil, nil)
+	invalidFile := "Party,Book\nA,1BKA\n"
+
+	if statusCode, _ := submitTestJob(t, manager, "run", invalidFile); statusCode != http.StatusAccepted {
+		t.Fatalf("expected the job to be accepted, got %d", statusCode)
+	}
+	manager.runJob(<-manager.queue)
+	if jobStatus := getTestJobStatus(t, manager, "run"); jobStatus.Stage != string(JOB_FAILED) || len(jobStatus.Error) == 0 {
+		t.Fatalf("expected the job to be %s with an error, got %+v", JOB_FAILED, jobStatus)
+	}
+
+	if statusCode, jobStatus := submitTestJob(t, manager, "run", TEST_INPUT_FILE); statusCode != http.StatusAccepted ||
+		jobStatus.Stage != string(JOB_QUEUED) {
+		t.Errorf("expected the failed job to be replaced, got %d %+v", statusCode, jobStatus)
+	}
+}
+
+func TestJobFromStoredRun(t *testing.T) {
+	runStore, err := NewRunStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager := NewJobManager(0, 1, runStore, nil, nil, nil)
+	if statusCode, _ := submitTestJob(t, manager, "run", TEST_INPUT_FILE); statusCode != http.StatusAccepted {
+		t.Fatalf("expected the job to be accepted, got %d", statusCode)
+	}
+	manager.runJob(<-manager.queue)
+
+	// a restarted server only has the run store
+	manager = NewJobManager(0, 1, runStore, nil, nil, nil)
+	if statusCode, jobStatus := submitTestJob(t, manager, "run", TEST_INPUT_FILE); statusCode != http.StatusOK ||
+		jobStatus.Stage != string(JOB_DONE) {
+		t.Errorf("expected the stored run to be returned as %s, got %d %+v", JOB_DONE, statusCode, jobStatus)
+	}
+	if statusCode, _ := submitTestJob(t, manager, "run", TEST_INPUT_FILE+"A,1BKA,A2,P,EUR,2022/12/31,D,CCP2,1\n"); statusCode != http.StatusConflict {
+		t.Errorf("expected status %d for different input, got %d", http.StatusConflict, statusCode)
+	}
+}
@@ -1,31 +1,55 @@
 package internal
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/gocarina/gocsv"
 	"github.com/sirupsen/logrus"
 	"github.com/zytan787/code-to-connect-2021/api"
 	"github.com/zytan787/code-to-connect-2021/internal/toolkit"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"strings"
 	"time"
 )
 
+const DEFAULT_MAX_UPLOADED_WHOLE_FILE_SIZE = 100 << 20
+
 type MainHandler struct {
-	PortfolioLoader   *PortfolioLoader
-	CompressionEngine *CompressionEngine
-	EventGenerator    *EventGenerator
-	DataChecker       *DataChecker
+	PortfolioLoader       *PortfolioLoader
+	CompressionEngine     *CompressionEngine
+	EventGenerator        *EventGenerator
+	DataChecker           *DataChecker
+	RunStore              *RunStore
+	ColumnMapping         ColumnMapping
+	ColumnMappingProfiles ColumnMappingProfiles
+	AttributeRule         AttributeRule
+	DateOptions           DateOptions
+	AllowedCurrencies     map[string]bool
+	TenorOptions          TenorOptions
+	HolidayCalendars      HolidayCalendars
+	BusinessDayConvention BusinessDayConvention
+	PairingOptions        PairingOptions
+	// MaxUploadedWholeFileSize limits the size of an uploaded XLSX or FpML file, which is read whole into memory
+	MaxUploadedWholeFileSize int64
+	runWriter                *RunWriter
+	runResult                *RunResult
+	onStageChange            func(stage JobStage)
 }
 
 func NewMainHandler() *MainHandler {
-	gocsv.FailIfUnmatchedStructTags = true
-
 	return &MainHandler{
-		PortfolioLoader:   &PortfolioLoader{},
-		CompressionEngine: &CompressionEngine{},
-		EventGenerator:    &EventGenerator{},
-		DataChecker:       &DataChecker{},
+		PortfolioLoader:          &PortfolioLoader{},
+		CompressionEngine:        &CompressionEngine{},
+		EventGenerator:           &EventGenerator{},
+		DataChecker:              &DataChecker{},
+		AttributeRule:            DEFAULT_ATTRIBUTE_RULE,
+		BusinessDayConvention:    BUSINESS_DAY_CONVENTION_NONE,
+		PairingOptions:           PairingOptions{CanonicalSide: CANONICAL_SIDE_PAY},
+		MaxUploadedWholeFileSize: DEFAULT_MAX_UPLOADED_WHOLE_FILE_SIZE,
 	}
 }
 
@@ -33,15 +57,28 @@ func (handler *MainHandler) CompressTrades(c *gin.Context) {
 	var req api.CompressTradesReq
 	var resp api.CompressTradesResp
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if _, err := GetResultFormat(c); err != nil {
 		resp.Error = err.Error()
 		c.JSON(http.StatusBadRequest, resp)
 		return
 	}
 
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error = err.Error()
+		WriteCompressTradesResp(c, http.StatusBadRequest, &resp)
+		return
+	}
+
 	if len(req.InputFiles) == 0 {
 		resp.Error = "input_files array has 0 element"
-		c.JSON(http.StatusBadRequest, resp)
+		WriteCompressTradesResp(c, http.StatusBadRequest, &resp)
+		return
+	}
+
+	options, err := parseRequestOptions(req)
+	if err != nil {
+		resp.Error = err.Error()
+		WriteCompressTradesResp(c, http.StatusBadRequest, &resp)
 		return
 	}
 
@@ -54,110 +91,390 @@ func (handler *MainHandler) CompressTrades(c *gin.Context) {
 		"request_id": req.RequestID,
 	})
 
+	handler.setRequestOptions(options)
+	status := handler.runCompression(req.InputFiles, &resp, logger)
+	WriteCompressTradesResult(c, status, &resp, handler.runResult)
+}
+
+func (handler *MainHandler) runCompression(inputFiles []api.File, resp *api.CompressTradesResp, logger *logrus.Entry) int {
+	if handler.RunStore != nil {
+		unlock := handler.RunStore.LockRequestID(resp.RequestID)
+		defer unlock()
+
+		inputHash, err := handler.hashInputFiles(inputFiles)
+		if err != nil {
+			resp.Error = fmt.Sprintf("Error in hashInputFiles due to: %s", err.Error())
+			return http.StatusBadRequest
+		}
+		if found, statusCode := handler.findStoredRun(inputHash, resp, logger); found {
+			return statusCode
+		}
+	}
+
+	if err := handler.startRun(resp.RequestID, inputFiles); err != nil {
+		resp.Error = fmt.Sprintf("Error in startRun due to: %s", err.Error())
+		return http.StatusBadRequest
+	}
+	defer handler.discardRun()
+
+	statusCode := handler.compressInputFiles(inputFiles, resp, logger)
+	if statusCode == http.StatusOK {
+		statusCode = handler.commitRun(resp, logger)
+	}
+	return statusCode
+}
+
+func (handler *MainHandler) compressInputFiles(inputFiles []api.File, resp *api.CompressTradesResp, logger *logrus.Entry) int {
+	handler.setStage(JOB_LOADING)
 	loadPortfolioStart := time.Now()
 
-	rawTrades, err := handler.DecodeInputFiles(req.InputFiles)
+	rawTrades, err := handler.DecodeInputFiles(inputFiles)
 	if err != nil {
 		resp.Error = fmt.Sprintf("Error in DecodeInputFiles due to: %s", err.Error())
-		c.JSON(http.StatusBadRequest, resp)
 		logger.Infof("Error in DecodeInputFiles due to: %s", err.Error())
+		return http.StatusBadRequest
+	}
+
+	if err = handler.LoadPortfolio(context.Background(), rawTrades); err != nil {
+		resp.Error = fmt.Sprintf("Error in LoadPortfolio due to: %s", err.Error())
+		logger.Infof("Error in LoadPortfolio due to: %s", err.Error())
+		return http.StatusInternalServerError
+	}
+
+	if handler.runWriter != nil {
+		for _, inputFile := range inputFiles {
+			err = handler.runWriter.SaveInputFile(inputFile.FileName, inputFile.SheetName,
+				base64.NewDecoder(base64.StdEncoding, strings.NewReader(inputFile.FileContent)))
+			if err != nil {
+				resp.Error = fmt.Sprintf("Error in SaveInputFile due to: %s", err.Error())
+				logger.Infof("Error in SaveInputFile due to: %s", err.Error())
+				return http.StatusInternalServerError
+			}
+		}
+	}
+
+	loadPortfolioDuration := time.Since(loadPortfolioStart)
+	logger.Infof("Done loading portfolio, took %s", loadPortfolioDuration)
+
+	return handler.generateResults(resp, logger)
+}
+
+func (handler *MainHandler) CompressUploadedTrades(c *gin.Context) {
+	var req api.CompressTradesReq
+	var resp api.CompressTradesResp
+
+	if _, err := GetResultFormat(c); err != nil {
+		resp.Error = err.Error()
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+
+	multipartReader, err := c.Request.MultipartReader()
+	if err != nil {
+		resp.Error = err.Error()
+		WriteCompressTradesResp(c, http.StatusBadRequest, &resp)
+		return
+	}
+
+	logger := logrus.WithFields(logrus.Fields{})
+	loadPortfolioStart := time.Now()
+	handler.StartLoadingPortfolio()
+
+	noOfFiles := 0
+	var part *multipart.Part
+	var replayHasher *InputHasher
+	for {
+		part, err = multipartReader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			resp.Error = fmt.Sprintf("unable to read multipart body due to: %s", err.Error())
+			WriteCompressTradesResp(c, http.StatusBadRequest, &resp)
+			return
+		}
+
+		if len(part.FileName()) > 0 {
+			if noOfFiles == 0 {
+				if len(req.RequestID) <= 0 {
+					req.RequestID = toolkit.UniqueID()
+				}
+				resp.RequestID = req.RequestID
+				logger = logrus.WithFields(logrus.Fields{
+					"request_id": req.RequestID,
+				})
+				options, err := parseRequestOptions(req)
+				if err != nil {
+					resp.Error = err.Error()
+					WriteCompressTradesResp(c, http.StatusBadRequest, &resp)
+					return
+				}
+				handler.setRequestOptions(options)
+
+				if handler.RunStore != nil {
+					unlock := handler.RunStore.LockRequestID(req.RequestID)
+					defer unlock()
+
+					if _, err = handler.RunStore.LoadRunSummary(req.RequestID); err == nil {
+						if replayHasher, err = handler.newInputHasher(req.InputFiles); err != nil {
+							resp.Error = err.Error()
+							WriteCompressTradesResp(c, http.StatusBadRequest, &resp)
+							return
+						}
+					}
+				}
+
+				if replayHasher == nil {
+					if err = handler.startRun(req.RequestID, req.InputFiles); err != nil {
+						resp.Error = fmt.Sprintf("Error in startRun due to: %s", err.Error())
+						WriteCompressTradesResp(c, http.StatusBadRequest, &resp)
+						return
+					}
+					defer handler.discardRun()
+				}
+			}
+			noOfFiles++
+
+			inputFile := getInputFile(req.InputFiles, part.FileName())
+			if replayHasher != nil {
+				err = replayHasher.AddFile(inputFile.FileName, inputFile.SheetName, part)
+			} else {
+				err = handler.loadUploadedFile(part, inputFile)
+			}
+			if err != nil {
+				resp.Error = fmt.Sprintf("Error in LoadInputFile due to: %s", err.Error())
+				WriteCompressTradesResp(c, http.StatusBadRequest, &resp)
+				logger.Infof("Error in LoadInputFile due to: %s", err.Error())
+				return
+			}
+		} else if part.FormName() == "request" {
+			if noOfFiles > 0 {
+				resp.Error = "the request field must come before any input file"
+				WriteCompressTradesResp(c, http.StatusBadRequest, &resp)
+				return
+			}
+			err = json.NewDecoder(part).Decode(&req)
+			if err != nil {
+				resp.Error = fmt.Sprintf("unable to decode the request field due to: %s", err.Error())
+				WriteCompressTradesResp(c, http.StatusBadRequest, &resp)
+				return
+			}
+		}
+		part.Close()
+	}
+
+	if noOfFiles == 0 {
+		resp.Error = "multipart body has 0 input file"
+		WriteCompressTradesResp(c, http.StatusBadRequest, &resp)
 		return
 	}
 
-	handler.LoadPortfolio(rawTrades)
+	if replayHasher != nil {
+		_, status := handler.findStoredRun(replayHasher.Sum(), &resp, logger)
+		WriteCompressTradesResp(c, status, &resp)
+		return
+	}
+
+	handler.FinishLoadingPortfolio()
 
 	loadPortfolioDuration := time.Since(loadPortfolioStart)
 	logger.Infof("Done loading portfolio, took %s", loadPortfolioDuration)
 
+	status := handler.generateResults(&resp, logger)
+	if status == http.StatusOK {
+		status = handler.commitRun(&resp, logger)
+	}
+	WriteCompressTradesResult(c, status, &resp, handler.runResult)
+}
+
+func (handler *MainHandler) loadUploadedFile(part *multipart.Part, inputFile api.File) error {
+	options, err := handler.getInputFileOptions(inputFile)
+	if err != nil {
+		return err
+	}
+	options.MaxWholeFileSize = handler.MaxUploadedWholeFileSize
+
+	if handler.runWriter == nil {
+		return handler.LoadInputFile(inputFile.FileName, options, part)
+	}
+
+	storedFile, err := handler.runWriter.CreateInputFile(inputFile.FileName, inputFile.SheetName)
+	if err != nil {
+		return err
+	}
+	defer storedFile.Close()
+
+	return handler.LoadInputFile(inputFile.FileName, options, io.TeeReader(part, storedFile))
+}
+
+// getInputFile returns the entry given for an uploaded file in the input_files of the request field,
+// which holds its sheet_name, column_mapping_profile and date_format
+func getInputFile(inputFiles []api.File, fileName string) api.File {
+	for _, inputFile := range inputFiles {
+		if inputFile.FileName == fileName {
+			return inputFile
+		}
+	}
+	return api.File{FileName: fileName}
+}
+
+func (handler *MainHandler) generateResults(resp *api.CompressTradesResp, logger *logrus.Entry) int {
+	handler.setStage(JOB_COMPRESSION)
 	compressionEngineStart := time.Now()
 
-	err = handler.GenerateCompressionResults()
+	err := handler.GenerateCompressionResults(context.Background())
 	if err != nil {
 		resp.Error = fmt.Sprintf("Error in GenerateCompressionResults due to: %s", err.Error())
-		c.JSON(http.StatusInternalServerError, resp)
 		logger.Infof("Error in GenerateCompressionResults due to: %s", err.Error())
-		return
+		return http.StatusInternalServerError
 	}
 
 	compressionEngineDuration := time.Since(compressionEngineStart)
 	logger.Infof("Done generating compression report, took %s", compressionEngineDuration)
 
+	handler.setStage(JOB_EVENT_GENERATION)
 	eventGeneratorStart := time.Now()
 
 	err = handler.GenerateProposals()
 	if err != nil {
 		resp.Error = fmt.Sprintf("Error in GenerateProposals due to: %s", err.Error())
-		c.JSON(http.StatusInternalServerError, resp)
 		logger.Infof("Error in GenerateProposals due to: %s", err.Error())
-		return
+		return http.StatusInternalServerError
 	}
 
 	eventGeneratorDuration := time.Since(eventGeneratorStart)
 	logger.Infof("Done generating proposals, took %s", eventGeneratorDuration)
 
+	handler.setStage(JOB_DATA_CHECK)
 	err = handler.CheckData()
 	if err != nil {
 		resp.Error = fmt.Sprintf("Error in CheckData due to: %s", err.Error())
-		c.JSON(http.StatusInternalServerError, resp)
 		logger.Infof("Error in CheckData due to: %s", err.Error())
-		return
+		return http.StatusInternalServerError
 	}
 
-	err = handler.GenerateBookLevelCompressionResults()
+	err = handler.GenerateBookLevelCompressionResults(context.Background())
 	if err != nil {
 		resp.Error = fmt.Sprintf("Error in GenerateBookLevelCompressionResults due to: %s", err.Error())
-		c.JSON(http.StatusInternalServerError, resp)
 		logger.Infof("Error in GenerateBookLevelCompressionResults due to: %s", err.Error())
-		return
+		return http.StatusInternalServerError
 	}
 
 	exclusionCSV, err := handler.GetExcludedTradesAsCSV()
 	if err != nil {
 		resp.Error = fmt.Sprintf("Error in GetExcludedTradesAsCSV due to: %s", err.Error())
-		c.JSON(http.StatusInternalServerError, resp)
 		logger.Infof("Error in GetExcludedTradesAsCSV due to: %s", err.Error())
-		return
+		return http.StatusInternalServerError
 	}
 	resp.Exclusion = exclusionCSV
 
 	compressionReport, err := handler.GetCompressionReportAsCSV()
 	if err != nil {
 		resp.Error = fmt.Sprintf("Error in GetCompressionReportAsCSV due to: %s", err.Error())
-		c.JSON(http.StatusInternalServerError, resp)
 		logger.Infof("Error in GetCompressionReportAsCSV due to: %s", err.Error())
-		return
+		return http.StatusInternalServerError
 	}
 	resp.CompressionReport = compressionReport
 
 	compressionReportBookLevel, err := handler.GetCompressionReportBookLevelAsCSV()
 	if err != nil {
 		resp.Error = fmt.Sprintf("Error in GetCompressionReportBookLevelAsCSV due to: %s", err.Error())
-		c.JSON(http.StatusInternalServerError, resp)
 		logger.Infof("Error in GetCompressionReportBookLevelAsCSV due to: %s", err.Error())
-		return
+		return http.StatusInternalServerError
 	}
 	resp.CompressionReportBookLevel = compressionReportBookLevel
 
 	proposals, err := handler.GetProposalsAsCSV()
 	if err != nil {
 		resp.Error = fmt.Sprintf("Error in GetProposalsAsCSV due to: %s", err.Error())
-		c.JSON(http.StatusInternalServerError, resp)
 		logger.Infof("Error in GetProposalsAsCSV due to: %s", err.Error())
-		return
+		return http.StatusInternalServerError
 	}
 	resp.Proposals = proposals
 
 	dataCheckResults, err := handler.GetDataCheckResultsAsCSV()
 	if err != nil {
 		resp.Error = fmt.Sprintf("Error in GetDataCheckResultsAsCSV due to: %s", err.Error())
-		c.JSON(http.StatusInternalServerError, resp)
 		logger.Infof("Error in GetProposalsAsCSV due to: %s", err.Error())
-		return
+		return http.StatusInternalServerError
 	}
 	resp.DataCheck = dataCheckResults
 
+	breakReport, err := handler.GetBreakReportAsCSV()
+	if err != nil {
+		resp.Error = fmt.Sprintf("Error in GetBreakReportAsCSV due to: %s", err.Error())
+		logger.Infof("Error in GetBreakReportAsCSV due to: %s", err.Error())
+		return http.StatusInternalServerError
+	}
+	resp.BreakReport = breakReport
+
 	statistics := handler.GetStatistics()
 	resp.Statistics = statistics
 
-	c.JSON(http.StatusOK, resp)
+	handler.runResult = handler.GetRunResult(resp.RequestID)
+	return http.StatusOK
+}
+
+func (handler *MainHandler) setStage(stage JobStage) {
+	if handler.onStageChange != nil {
+		handler.onStageChange(stage)
+	}
+}
+
+func (handler *MainHandler) startRun(requestID string, inputFiles []api.File) error {
+	if handler.RunStore == nil {
+		return nil
+	}
+
+	inputHasher, err := handler.newInputHasher(inputFiles)
+	if err != nil {
+		return err
+	}
+	runWriter, err := handler.RunStore.NewRunWriter(requestID, inputHasher)
+	if err != nil {
+		return err
+	}
+	handler.runWriter = runWriter
+	return nil
+}
+
+func (handler *MainHandler) findStoredRun(inputHash string, resp *api.CompressTradesResp, logger *logrus.Entry) (bool, int) {
+	storedResp, err := handler.RunStore.FindRun(resp.RequestID, inputHash)
+	if err == ErrRequestIDConflict {
+		resp.Error = fmt.Sprintf("request_id %s was already used with different input files or options", resp.RequestID)
+		logger.Infof("Conflicting resubmission of request_id %s", resp.RequestID)
+		return true, http.StatusConflict
+	}
+	if err != nil {
+		resp.Error = fmt.Sprintf("Error in FindRun due to: %s", err.Error())
+		logger.Infof("Error in FindRun due to: %s", err.Error())
+		return true, http.StatusInternalServerError
+	}
+	if storedResp == nil {
+		return false, http.StatusOK
+	}
+
+	logger.Infof("Returning the stored run for resubmitted request_id %s", resp.RequestID)
+	*resp = *storedResp
+	return true, http.StatusOK
+}
+
+func (handler *MainHandler) commitRun(resp *api.CompressTradesResp, logger *logrus.Entry) int {
+	if handler.runWriter == nil {
+		return http.StatusOK
+	}
+
+	err := handler.runWriter.Commit(resp)
+	if err != nil {
+		resp.Error = fmt.Sprintf("Error in commitRun due to: %s", err.Error())
+		logger.Infof("Error in commitRun due to: %s", err.Error())
+		return http.StatusInternalServerError
+	}
+	return http.StatusOK
+}
+
+func (handler *MainHandler) discardRun() {
+	if handler.runWriter != nil {
+		handler.runWriter.Discard()
+	}
 }
@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+	"github.com/zytan787/code-to-connect-2021/api"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testPart struct {
+	formName string
+	fileName string
+	content  []byte
+}
+
+func newTestXLSXFile(t *testing.T, content string) []byte {
+	t.Helper()
+
+	file := excelize.NewFile()
+	for i, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		values := strings.Split(line, ",")
+		row := make([]interface{}, len(values))
+		for j, value := range values {
+			row[j] = value
+		}
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = file.SetSheetRow(file.GetSheetName(0), cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buffer, err := file.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func uploadTestFiles(t *testing.T, handler *MainHandler, parts []testPart) (int, *api.CompressTradesResp) {
+	t.Helper()
+
+	var body bytes.Buffer
+	multipartWriter := multipart.NewWriter(&body)
+	for _, part := range parts {
+		var err error
+		if len(part.fileName) > 0 {
+			partWriter, createErr := multipartWriter.CreateFormFile(part.formName, part.fileName)
+			if createErr != nil {
+				t.Fatal(createErr)
+			}
+			_, err = partWriter.Write(part.content)
+		} else {
+			err = multipartWriter.WriteField(part.formName, string(part.content))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := multipartWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/compress_trades/upload", &body)
+	c.Request.Header.Set("Content-Type", multipartWriter.FormDataContentType())
+	handler.CompressUploadedTrades(c)
+
+	var resp api.CompressTradesResp
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unable to decode response %s due to: %s", recorder.Body.String(), err.Error())
+	}
+	return recorder.Code, &resp
+}
+
+func TestCompressUploadedTrades(t *testing.T) {
+	xlsxFile := newTestXLSXFile(t, TEST_INPUT_FILE)
+	tests := []struct {
+		name                     string
+		maxUploadedWholeFileSize int64
+		parts                    []testPart
+		statusCode               int
+		error                    string
+	}{
+		{"csv file", 0, []testPart{
+			{"request", "", []byte(`{"request_id": "run"}`)},
+			{"file", "trades.csv", []byte(TEST_INPUT_FILE)},
+		}, http.StatusOK, ""},
+		{"xlsx file within the limit", int64(len(xlsxFile)), []testPart{
+			{"file", "trades.xlsx", xlsxFile},
+		}, http.StatusOK, ""},
+		{"xlsx file over the limit", int64(len(xlsxFile)) - 1, []testPart{
+			{"file", "trades.xlsx", xlsxFile},
+		}, http.StatusBadRequest, "XLSX file is larger than"},
+		{"fpml file over the limit", 10, []testPart{
+			{"file", "trades.xml", []byte("<dataDocument><trade></trade></dataDocument>")},
+		}, http.StatusBadRequest, "FpML file is larger than 10 bytes"},
+		{"csv file over the limit of xlsx files", 10, []testPart{
+			{"file", "trades.csv", []byte(TEST_INPUT_FILE)},
+		}, http.StatusOK, ""},
+		{"request after a file", 0, []testPart{
+			{"file", "trades.csv", []byte(TEST_INPUT_FILE)},
+			{"request", "", []byte(`{"request_id": "run"}`)},
+		}, http.StatusBadRequest, "the request field must come before any input file"},
+		{"no file", 0, []testPart{
+			{"request", "", []byte(`{"request_id": "run"}`)},
+		}, http.StatusBadRequest, "multipart body has 0 input file"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := NewMainHandler()
+			handler.MaxUploadedWholeFileSize = test.maxUploadedWholeFileSize
+
+			statusCode, resp := uploadTestFiles(t, handler, test.parts)
+			if statusCode != test.statusCode || !strings.Contains(resp.Error, test.error) {
+				t.Fatalf("expected status %d with error %q, got %d %q", test.statusCode, test.error, statusCode, resp.Error)
+			}
+			if statusCode != http.StatusOK {
+				return
+			}
+
+			proposals, err := base64.StdEncoding.DecodeString(resp.Proposals[0].Proposal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(proposals), "367749") {
+				t.Errorf("expected a new trade of 367749, got %s", proposals)
+			}
+		})
+	}
+}
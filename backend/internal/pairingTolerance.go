@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/zytan787/code-to-connect-2021/internal/toolkit"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const SECONDS_PER_DAY = 24 * 60 * 60
+
+const (
+	// the trade that pays wins
+	CANONICAL_SIDE_PAY = "pay"
+	// the trade that receives wins
+	CANONICAL_SIDE_RECEIVE = "receive"
+)
+
+// PairingOptions accepts the two sides of a cleared trade when their notionals or maturity dates differ within
+// tolerance, the values of the canonical side are then used for both trades. The zero value pairs exact matches only.
+type PairingOptions struct {
+	// NotionalTolerance is the largest accepted difference between the notionals
+	NotionalTolerance toolkit.Decimal
+	// RelativeNotionalTolerance is the largest accepted difference as a fraction of the larger notional, like 0.0001
+	RelativeNotionalTolerance toolkit.Decimal
+	// MaturityToleranceDays is the largest accepted number of days between the adjusted maturity dates
+	MaturityToleranceDays int
+	// CanonicalSide is CANONICAL_SIDE_PAY, CANONICAL_SIDE_RECEIVE or a party, like the CCP, whose trade wins
+	// when it is one of the two sides and the pay side otherwise
+	CanonicalSide string
+}
+
+func ParsePairingOptions(notionalTolerance string, relativeNotionalTolerance string, maturityToleranceDays int,
+	canonicalSide string) (PairingOptions, error) {
+	options := PairingOptions{
+		MaturityToleranceDays: maturityToleranceDays,
+		CanonicalSide:         strings.TrimSpace(canonicalSide),
+	}
+	var err error
+
+	if len(strings.TrimSpace(notionalTolerance)) > 0 {
+		if options.NotionalTolerance, err = toolkit.ParseDecimal(notionalTolerance); err != nil {
+			return options, fmt.Errorf("notional_tolerance %s", err.Error())
+		}
+		if options.NotionalTolerance.Sign() < 0 {
+			return options, fmt.Errorf("notional_tolerance %s is a negative value", notionalTolerance)
+		}
+	}
+
+	if len(strings.TrimSpace(relativeNotionalTolerance)) > 0 {
+		if options.RelativeNotionalTolerance, err = toolkit.ParseDecimal(relativeNotionalTolerance); err != nil {
+			return options, fmt.Errorf("relative_notional_tolerance %s", err.Error())
+		}
+		if options.RelativeNotionalTolerance.Sign() < 0 {
+			return options, fmt.Errorf("relative_notional_tolerance %s is a negative value", relativeNotionalTolerance)
+		}
+	}
+
+	if maturityToleranceDays < 0 {
+		return options, fmt.Errorf("maturity_tolerance_days %d is a negative value", maturityToleranceDays)
+	}
+
+	switch strings.ToLower(options.CanonicalSide) {
+	case "", CANONICAL_SIDE_PAY:
+		options.CanonicalSide = CANONICAL_SIDE_PAY
+	case CANONICAL_SIDE_RECEIVE:
+		options.CanonicalSide = CANONICAL_SIDE_RECEIVE
+	}
+	return options, nil
+}
+
+// isNotionalWithinTolerance reports whether the notionals differ by at most the absolute or the relative tolerance
+func (options PairingOptions) isNotionalWithinTolerance(notional1 toolkit.Decimal, notional2 toolkit.Decimal) bool {
+	difference, err := notional1.Sub(notional2)
+	if err != nil {
+		return false
+	}
+	difference = difference.Abs()
+	if difference.Cmp(options.NotionalTolerance) <= 0 {
+		return true
+	}
+	if options.RelativeNotionalTolerance.IsZero() {
+		return false
+	}
+
+	largerNotional := notional1
+	if notional2.Cmp(notional1) > 0 {
+		largerNotional = notional2
+	}
+	limit := new(big.Rat).Mul(options.RelativeNotionalTolerance.Rat(), largerNotional.Rat())
+	return difference.Rat().Cmp(limit) <= 0
+}
+
+// isMaturityWithinTolerance compares whole calendar days, a time.Duration would overflow for tolerances of
+// hundreds of years and for dates that far apart
+func (options PairingOptions) isMaturityWithinTolerance(maturityDate1 time.Time, maturityDate2 time.Time) bool {
+	days := (maturityDate1.Unix() - maturityDate2.Unix()) / SECONDS_PER_DAY
+	if days < 0 {
+		days = -days
+	}
+	return days <= int64(options.MaturityToleranceDays)
+}
+
+// getCanonicalTrade returns the trade of the pair whose values are used for both trades
+func (options PairingOptions) getCanonicalTrade(trade1 *Trade, trade2 *Trade) *Trade {
+	switch options.CanonicalSide {
+	case CANONICAL_SIDE_PAY:
+		return getPayTrade(trade1, trade2)
+	case CANONICAL_SIDE_RECEIVE:
+		if trade1.PayOrReceive == "R" {
+			return trade1
+		}
+		return trade2
+	}
+
+	// the canonical side is a party, which wins when it is one of the two sides, the pay side wins otherwise
+	if trade1.Party == options.CanonicalSide {
+		return trade1
+	}
+	if trade2.Party == options.CanonicalSide {
+		return trade2
+	}
+	return getPayTrade(trade1, trade2)
+}
+
+func getPayTrade(trade1 *Trade, trade2 *Trade) *Trade {
+	if trade1.PayOrReceive == "P" {
+		return trade1
+	}
+	return trade2
+}
+
+// reconcilePairedTrades copies the notional and adjusted maturity date of the canonical trade to the other trade,
+// which keeps its own values as submitted, and sets a warning listing the differences and the replaced values on both trades
+func (options PairingOptions) reconcilePairedTrades(trade1 *Trade, trade2 *Trade, differences []string) {
+	canonicalTrade := options.getCanonicalTrade(trade1, trade2)
+	otherTrade := trade1
+	if canonicalTrade == trade1 {
+		otherTrade = trade2
+	}
+
+	replacedValues := make([]string, 0, 2)
+	if otherTrade.Notional.Cmp(canonicalTrade.Notional) != 0 {
+		replacedValues = append(replacedValues, fmt.Sprintf("Notional %s", otherTrade.Notional))
+	}
+	if !otherTrade.AdjustedMaturityDate.Equal(canonicalTrade.AdjustedMaturityDate) {
+		replacedValues = append(replacedValues, fmt.Sprintf("AdjustedMaturityDate %s", otherTrade.AdjustedMaturityDate.Format(DATE_FORMAT)))
+	}
+
+	otherTrade.Notional = canonicalTrade.Notional
+	otherTrade.AdjustedMaturityDate = canonicalTrade.AdjustedMaturityDate
+
+	warning := fmt.Sprintf("trades with CCPTradeID=%s are paired within tolerance with %s, the values of party %s are used instead of %s submitted by party %s",
+		trade1.CCPTradeID, strings.Join(differences, " and "), canonicalTrade.Party, strings.Join(replacedValues, " and "), otherTrade.Party)
+	trade1.Warning = warning
+	trade2.Warning = warning
+}
@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"github.com/zytan787/code-to-connect-2021/internal/toolkit"
+	"testing"
+)
+
+func newTestPairedTrades(t *testing.T, notional1 string, maturityDate1 string, notional2 string, maturityDate2 string) (*Trade, *Trade) {
+	t.Helper()
+
+	newTrade := func(party string, cpty string, payOrReceive string, notional string, maturityDate string) *Trade {
+		parsedNotional, err := toolkit.ParseDecimal(notional)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsedMaturityDate := mustParseDate(t, maturityDate)
+		return &Trade{
+			Party:                         party,
+			TradeID:                       party + "1",
+			PayOrReceive:                  payOrReceive,
+			Currency:                      "USD",
+			MaturityDate:                  parsedMaturityDate,
+			AdjustedMaturityDate:          parsedMaturityDate,
+			Cpty:                          cpty,
+			CCPTradeID:                    "C1",
+			Notional:                      parsedNotional,
+			SubmittedNotional:             parsedNotional,
+			SubmittedAdjustedMaturityDate: parsedMaturityDate,
+		}
+	}
+	return newTrade("A", "B", "P", notional1, maturityDate1), newTrade("B", "A", "R", notional2, maturityDate2)
+}
+
+func TestVerifyPairedTradesWithinTolerance(t *testing.T) {
+	tests := []struct {
+		name                      string
+		notionalTolerance         string
+		relativeNotionalTolerance string
+		maturityToleranceDays     int
+		notional1                 string
+		notional2                 string
+		maturityDate2             string
+		paired                    bool
+	}{
+		{"same values", "", "", 0, "1000000", "1000000", "2030/06/14", true},
+		{"same values written differently", "", "", 0, "1000000", "1000000.00", "2030/06/14", true},
+		{"different notionals without tolerance", "", "", 0, "1000000", "1000000.01", "2030/06/14", false},
+		{"inside the absolute tolerance", "0.5", "", 0, "1000000", "1000000.50", "2030/06/14", true},
+		{"outside the absolute tolerance", "0.5", "", 0, "1000000", "1000000.51", "2030/06/14", false},
+		{"inside the relative tolerance", "", "0.0001", 0, "1000000", "1000100", "2030/06/14", true},
+		{"outside the relative tolerance", "", "0.0001", 0, "1000000", "1000100.02", "2030/06/14", false},
+		{"relative tolerance of the larger notional", "", "0.0001", 0, "1000100", "1000000", "2030/06/14", true},
+		{"outside the absolute but inside the relative tolerance", "1", "0.0001", 0, "1000000", "1000050", "2030/06/14", true},
+		{"different maturity dates without tolerance", "", "", 0, "1000000", "1000000", "2030/06/15", false},
+		{"maturity at the tolerance", "", "", 3, "1000000", "1000000", "2030/06/17", true},
+		{"maturity before the tolerance", "", "", 3, "1000000", "1000000", "2030/06/11", true},
+		{"maturity past the tolerance", "", "", 3, "1000000", "1000000", "2030/06/18", false},
+		{"maturity with a tolerance of centuries", "", "", 200000, "1000000", "1000000", "2530/06/15", true},
+		{"maturity centuries past the tolerance", "", "", 1, "1000000", "1000000", "2530/06/15", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options, err := ParsePairingOptions(test.notionalTolerance, test.relativeNotionalTolerance, test.maturityToleranceDays, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			trade1, trade2 := newTestPairedTrades(t, test.notional1, "2030/06/14", test.notional2, test.maturityDate2)
+			err = verifyPairedTrades(trade1, trade2, options)
+			if test.paired && err != nil {
+				t.Errorf("expected the trades to be paired, got %s", err.Error())
+			}
+			if !test.paired && err == nil {
+				t.Errorf("expected the trades not to be paired")
+			}
+		})
+	}
+}
+
+func TestReconcilePairedTrades(t *testing.T) {
+	tests := []struct {
+		name           string
+		canonicalSide  string
+		canonicalParty string
+		warning        string
+	}{
+		{"pay side", "", "A",
+			"trades with CCPTradeID=C1 are paired within tolerance with different notionals: 1000000 and 1000001 and " +
+				"different MaturityDate: 2030/06/14 and 2030/06/15, the values of party A are used instead of " +
+				"Notional 1000001 and AdjustedMaturityDate 2030/06/15 submitted by party B"},
+		{"pay side by name", "PAY", "A", ""},
+		{"receive side", "receive", "B",
+			"trades with CCPTradeID=C1 are paired within tolerance with different notionals: 1000000 and 1000001 and " +
+				"different MaturityDate: 2030/06/14 and 2030/06/15, the values of party B are used instead of " +
+				"Notional 1000000 and AdjustedMaturityDate 2030/06/14 submitted by party A"},
+		{"party on the receive side", "B", "B", ""},
+		{"party on the pay side", "A", "A", ""},
+		{"party that is neither side", "CCP", "A", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options, err := ParsePairingOptions("1", "", 1, test.canonicalSide)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			trade1, trade2 := newTestPairedTrades(t, "1000000", "2030/06/14", "1000001", "2030/06/15")
+			if err = verifyPairedTrades(trade1, trade2, options); err != nil {
+				t.Fatal(err)
+			}
+
+			canonicalTrade, otherTrade := trade1, trade2
+			if test.canonicalParty == trade2.Party {
+				canonicalTrade, otherTrade = trade2, trade1
+			}
+			if otherTrade.Notional.Cmp(canonicalTrade.SubmittedNotional) != 0 ||
+				!otherTrade.AdjustedMaturityDate.Equal(canonicalTrade.SubmittedAdjustedMaturityDate) {
+				t.Errorf("expected the values of party %s to be copied, got Notional %s and AdjustedMaturityDate %s",
+					canonicalTrade.Party, otherTrade.Notional, otherTrade.AdjustedMaturityDate.Format(DATE_FORMAT))
+			}
+			if canonicalTrade.Notional.Cmp(canonicalTrade.SubmittedNotional) != 0 ||
+				!canonicalTrade.AdjustedMaturityDate.Equal(canonicalTrade.SubmittedAdjustedMaturityDate) {
+				t.Errorf("expected the values of party %s to be kept", canonicalTrade.Party)
+			}
+			if otherTrade.SubmittedNotional.Cmp(otherTrade.Notional) == 0 ||
+				otherTrade.SubmittedAdjustedMaturityDate.Equal(otherTrade.AdjustedMaturityDate) {
+				t.Errorf("expected party %s to keep its submitted values", otherTrade.Party)
+			}
+
+			if trade1.Warning != trade2.Warning {
+				t.Errorf("expected the same warning on both trades, got %q and %q", trade1.Warning, trade2.Warning)
+			}
+			if len(test.warning) > 0 && trade1.Warning != test.warning {
+				t.Errorf("expected warning %q, got %q", test.warning, trade1.Warning)
+			}
+		})
+	}
+}
@@ -1,11 +1,16 @@
 package internal
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
-	"github.com/araddon/dateparse"
 	"github.com/gocarina/gocsv"
 	"github.com/zytan787/code-to-connect-2021/api"
+	"github.com/zytan787/code-to-connect-2021/internal/toolkit"
+	"io"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -15,58 +20,216 @@ import (
 type PortfolioLoader struct {
 	CcpTradeIDToCompressibleTrades map[string][]*Trade
 	ExcludedTrades                 []*ExcludedTrade
+	BreakReport                    []*BreakCandidate
+	ccpTradeIDToCleanTrades        map[string][]*Trade
+	partyTradeIDToCleanTrades      map[partyTradeID][]*Trade
+	cleanTradesWithoutCCPTradeID   []*Trade
+	// breakTrades could not be paired, the break report lists their likely counterparts
+	breakTrades []*Trade
+}
+
+type partyTradeID struct {
+	party   string
+	tradeID string
 }
 
 func (handler *MainHandler) DecodeInputFiles(inputFiles []api.File) ([]*RawTrade, error) {
 	result := make([]*RawTrade, 0)
 
 	var fileBytes []byte
+	var options InputFileOptions
 	var err error
 	for _, inputFile := range inputFiles {
 		fileBytes, err = base64.StdEncoding.DecodeString(inputFile.FileContent)
 		if err != nil {
 			return nil, fmt.Errorf("unable to decode the base64 string of file %s due to: %s", inputFile.FileName, err.Error())
 		}
-		trades := make([]*RawTrade, 0)
-		err = gocsv.UnmarshalBytes(fileBytes, &trades)
+		options, err = handler.getInputFileOptions(inputFile)
+		if err != nil {
+			return nil, err
+		}
+		err = readRawTrades(inputFile.FileName, options, bytes.NewReader(fileBytes), func(rawTrade *RawTrade) {
+			result = append(result, rawTrade)
+		})
 		if err != nil {
 			return nil, fmt.Errorf("unable to unmarshal bytes into trades for file %s due to: %s, "+
-				"make sure your CSV file has the correct format", inputFile.FileName, err.Error())
+				"make sure your CSV, XLSX or FpML file has the correct format", inputFile.FileName, err.Error())
 		}
-		result = append(result, trades...)
 	}
 
 	return result, nil
 }
 
-func (handler *MainHandler) LoadPortfolio(rawTrades []*RawTrade) {
-	ccpTradeIDToCompressibleTrades, excludedTrades := categorizeRawTrades(rawTrades)
+// LoadPortfolio stops with the error of ctx once it is cancelled, leaving the portfolio partly loaded
+func (handler *MainHandler) LoadPortfolio(ctx context.Context, rawTrades []*RawTrade) error {
+	handler.StartLoadingPortfolio()
+	for _, rawTrade := range rawTrades {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		handler.PortfolioLoader.addRawTrade(rawTrade, handler.DateOptions, handler.AllowedCurrencies)
+	}
+	handler.FinishLoadingPortfolio()
+	return nil
+}
+
+func (handler *MainHandler) StartLoadingPortfolio() {
+	handler.PortfolioLoader.CcpTradeIDToCompressibleTrades = nil
+	handler.PortfolioLoader.ExcludedTrades = nil
+	handler.PortfolioLoader.BreakReport = nil
+	handler.PortfolioLoader.ccpTradeIDToCleanTrades = make(map[string][]*Trade)
+	handler.PortfolioLoader.partyTradeIDToCleanTrades = make(map[partyTradeID][]*Trade)
+	handler.PortfolioLoader.cleanTradesWithoutCCPTradeID = nil
+	handler.PortfolioLoader.breakTrades = nil
+}
+
+func (handler *MainHandler) LoadInputFile(fileName string, options InputFileOptions, in io.Reader) error {
+	err := readRawTrades(fileName, options, in, func(rawTrade *RawTrade) {
+		handler.PortfolioLoader.addRawTrade(rawTrade, handler.DateOptions, handler.AllowedCurrencies)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to unmarshal trades for file %s due to: %s, "+
+			"make sure your CSV, XLSX or FpML file has the correct format", fileName, err.Error())
+	}
+	return nil
+}
 
-	handler.PortfolioLoader.CcpTradeIDToCompressibleTrades = ccpTradeIDToCompressibleTrades
-	handler.PortfolioLoader.ExcludedTrades = excludedTrades
+func (handler *MainHandler) FinishLoadingPortfolio() {
+	handler.PortfolioLoader.categorizeCleanTrades(handler.HolidayCalendars, handler.BusinessDayConvention, handler.TenorOptions,
+		handler.PairingOptions)
 }
 
-func categorizeRawTrades(rawTrades []*RawTrade) (ccpTradeIDToCompressibleTrades map[string][]*Trade, excludedTrades []*ExcludedTrade) {
-	tmpCleanTrades := make(map[string][]*Trade)
+func readRawTrades(fileName string, options InputFileOptions, in io.Reader, onRawTrade func(rawTrade *RawTrade)) error {
+	baseFileName := filepath.Base(fileName)
+	onFileRawTrade := func(rawTrade *RawTrade) {
+		rawTrade.FileName = baseFileName
+		rawTrade.DateFormat = options.DateFormat
+		onRawTrade(rawTrade)
+	}
 
-	var cleanTrade *Trade
-	var err error
-	for _, rawTrade := range rawTrades {
-		cleanTrade, err = cleanRawTrade(rawTrade)
-		if err == nil {
-			tmpCleanTrades[cleanTrade.CCPTradeID] = append(tmpCleanTrades[cleanTrade.CCPTradeID], cleanTrade)
+	bufferedIn := bufio.NewReader(in)
+	if isXLSXFile(fileName, bufferedIn) {
+		return readXLSXRawTrades(newWholeFileReader(bufferedIn, "XLSX", options.MaxWholeFileSize), options, onFileRawTrade)
+	}
+	if len(options.SheetName) > 0 {
+		return fmt.Errorf("sheet_name %s is given but the file is not an XLSX file", options.SheetName)
+	}
+	if isFpMLFile(fileName, bufferedIn) {
+		return readFpMLRawTrades(fileName, newWholeFileReader(bufferedIn, "FpML", options.MaxWholeFileSize), onFileRawTrade)
+	}
+
+	reader, err := NewRawTradeReader(bufferedIn, options.ColumnMapping)
+	if err != nil {
+		return err
+	}
+
+	var rawTrade *RawTrade
+	for {
+		rawTrade, err = reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		onFileRawTrade(rawTrade)
+	}
+}
+
+// wholeFileReader fails once more than maxSize bytes are read from a file that is read whole into memory
+type wholeFileReader struct {
+	in       io.Reader
+	fileType string
+	maxSize  int64
+	size     int64
+}
+
+func newWholeFileReader(in io.Reader, fileType string, maxSize int64) io.Reader {
+	if maxSize <= 0 {
+		return in
+	}
+	return &wholeFileReader{in: in, fileType: fileType, maxSize: maxSize}
+}
+
+func (reader *wholeFileReader) Read(p []byte) (int, error) {
+	n, err := reader.in.Read(p)
+	reader.size += int64(n)
+	if reader.size > reader.maxSize {
+		return n, fmt.Errorf("%s file is larger than %d bytes, the largest %s file that can be uploaded, "+
+			"upload it as CSV instead", reader.fileType, reader.maxSize, reader.fileType)
+	}
+	return n, err
+}
+
+func (loader *PortfolioLoader) addRawTrade(rawTrade *RawTrade, dateOptions DateOptions, allowedCurrencies map[string]bool) {
+	cleanTrade, err := cleanRawTrade(rawTrade, dateOptions, allowedCurrencies)
+	if err == nil {
+		if len(cleanTrade.CCPTradeID) > 0 {
+			loader.ccpTradeIDToCleanTrades[cleanTrade.CCPTradeID] = append(loader.ccpTradeIDToCleanTrades[cleanTrade.CCPTradeID], cleanTrade)
 		} else {
-			excludedTrades = append(excludedTrades, createExcludedTradeFromRawTrade(rawTrade, err))
+			loader.cleanTradesWithoutCCPTradeID = append(loader.cleanTradesWithoutCCPTradeID, cleanTrade)
+		}
+		key := partyTradeID{party: cleanTrade.Party, tradeID: cleanTrade.TradeID}
+		loader.partyTradeIDToCleanTrades[key] = append(loader.partyTradeIDToCleanTrades[key], cleanTrade)
+	} else {
+		loader.ExcludedTrades = append(loader.ExcludedTrades, createExcludedTradeFromRawTrade(rawTrade, err))
+	}
+}
+
+func (loader *PortfolioLoader) categorizeCleanTrades(calendars HolidayCalendars, convention BusinessDayConvention,
+	tenorOptions TenorOptions, pairingOptions PairingOptions) {
+	ccpTradeIDToCompressibleTrades := make(map[string][]*Trade)
+	excludedTrades := loader.ExcludedTrades
+
+	// exclusionReasons holds why a clean trade is excluded before pairing, to explain the exclusion of its paired trade
+	exclusionReasons := make(map[*Trade]string)
+	for _, cleanTrades := range loader.partyTradeIDToCleanTrades {
+		for _, cleanTrade := range cleanTrades {
+			adjustedMaturityDate, err := calendars.adjustDate(cleanTrade.MaturityDate, cleanTrade.Currency, convention)
+			if err != nil {
+				excludedTrades = append(excludedTrades, createExcludedTradeFromCleanTrades([]*Trade{cleanTrade}, err.Error())...)
+				exclusionReasons[cleanTrade] = "its maturity date"
+				continue
+			}
+			cleanTrade.AdjustedMaturityDate = adjustedMaturityDate
+			cleanTrade.SubmittedAdjustedMaturityDate = cleanTrade.AdjustedMaturityDate
+			if err = tenorOptions.checkResidualTenor(cleanTrade, calendars); err != nil {
+				excludedTrades = append(excludedTrades, createExcludedTradeFromCleanTrades([]*Trade{cleanTrade}, err.Error())...)
+				exclusionReasons[cleanTrade] = "its residual tenor"
+			}
+		}
+	}
+
+	for key, cleanTrades := range loader.partyTradeIDToCleanTrades {
+		cleanTrades = removeExcludedTrades(cleanTrades, exclusionReasons)
+		if len(cleanTrades) > 1 {
+			excludedTrades = append(excludedTrades, createExcludedTradeFromCleanTrades(cleanTrades, getDuplicateTradeIDError(key, cleanTrades))...)
+			for _, cleanTrade := range cleanTrades {
+				exclusionReasons[cleanTrade] = "a duplicate TradeID"
+			}
 		}
 	}
 
-	ccpTradeIDToCompressibleTrades = make(map[string][]*Trade)
+	excludedTrades = append(excludedTrades, loader.matchByEconomicTerms(exclusionReasons)...)
+
 	var compressible bool
-	for CCPTradeID, seenCleanTrades := range tmpCleanTrades {
+	var err error
+	for CCPTradeID, seenCleanTrades := range loader.ccpTradeIDToCleanTrades {
 		compressible = false
 
+		if len(exclusionReasons) > 0 {
+			pairedTrades := removeExcludedTrades(seenCleanTrades, exclusionReasons)
+			if len(pairedTrades) < len(seenCleanTrades) {
+				reason := getFirstExclusionReason(seenCleanTrades, exclusionReasons)
+				excludedTrades = append(excludedTrades, createExcludedTradeFromCleanTrades(pairedTrades,
+					fmt.Sprintf("paired trade with CCPTradeID=%s is excluded for %s", CCPTradeID, reason))...)
+				loader.breakTrades = append(loader.breakTrades, pairedTrades...)
+				continue
+			}
+		}
+
 		if len(seenCleanTrades) == 2 {
-			err = verifyPairedTrades(seenCleanTrades[0], seenCleanTrades[1])
+			err = verifyPairedTrades(seenCleanTrades[0], seenCleanTrades[1], pairingOptions)
 			if err == nil {
 				compressible = true
 			}
@@ -75,6 +238,7 @@ func categorizeRawTrades(rawTrades []*RawTrade) (ccpTradeIDToCompressibleTrades
 		if compressible {
 			ccpTradeIDToCompressibleTrades[CCPTradeID] = seenCleanTrades
 		} else {
+			loader.breakTrades = append(loader.breakTrades, seenCleanTrades...)
 			if len(seenCleanTrades) == 1 {
 				excludedTrades = append(excludedTrades, createExcludedTradeFromCleanTrades(seenCleanTrades, "trade not submitted on both sides")...)
 			} else if len(seenCleanTrades) == 2 {
@@ -84,48 +248,128 @@ func categorizeRawTrades(rawTrades []*RawTrade) (ccpTradeIDToCompressibleTrades
 			}
 		}
 	}
-	return
+
+	cleanTrades := make([]*Trade, 0)
+	for _, partyCleanTrades := range loader.partyTradeIDToCleanTrades {
+		cleanTrades = append(cleanTrades, partyCleanTrades...)
+	}
+
+	loader.CcpTradeIDToCompressibleTrades = ccpTradeIDToCompressibleTrades
+	loader.ExcludedTrades = excludedTrades
+	loader.BreakReport = generateBreakReport(loader.breakTrades, cleanTrades)
+	loader.ccpTradeIDToCleanTrades = nil
+	loader.partyTradeIDToCleanTrades = nil
+	loader.cleanTradesWithoutCCPTradeID = nil
+	loader.breakTrades = nil
+}
+
+// getDuplicateTradeIDError names the rows of every trade of a party with the same TradeID, in the order they were read
+func getDuplicateTradeIDError(key partyTradeID, cleanTrades []*Trade) string {
+	errorMessage := fmt.Sprintf("duplicate TradeID %s for party %s", key.tradeID, key.party)
+
+	locations := make([]string, 0, len(cleanTrades))
+	for _, cleanTrade := range cleanTrades {
+		location := getTradeLocation(cleanTrade)
+		if len(location) == 0 {
+			return errorMessage
+		}
+		locations = append(locations, location)
+	}
+	return fmt.Sprintf("%s, found at %s and %s", errorMessage,
+		strings.Join(locations[:len(locations)-1], ", "), locations[len(locations)-1])
+}
+
+func removeExcludedTrades(cleanTrades []*Trade, exclusionReasons map[*Trade]string) []*Trade {
+	result := make([]*Trade, 0, len(cleanTrades))
+	for _, cleanTrade := range cleanTrades {
+		if _, ok := exclusionReasons[cleanTrade]; !ok {
+			result = append(result, cleanTrade)
+		}
+	}
+	return result
+}
+
+func getFirstExclusionReason(cleanTrades []*Trade, exclusionReasons map[*Trade]string) string {
+	for _, cleanTrade := range cleanTrades {
+		if reason, ok := exclusionReasons[cleanTrade]; ok {
+			return reason
+		}
+	}
+	return ""
+}
+
+// verifyPairedTrades checks that two trades are the two sides of a cleared trade, when their notionals or maturity
+// dates differ within tolerance the values of the canonical side are used for both trades
+func verifyPairedTrades(trade1 *Trade, trade2 *Trade, options PairingOptions) error {
+	differences, err := comparePairedTrades(trade1, trade2, options)
+	if err == nil {
+		if len(differences) > 0 {
+			options.reconcilePairedTrades(trade1, trade2, differences)
+		}
+		return nil
+	}
+
+	location1 := getTradeLocation(trade1)
+	location2 := getTradeLocation(trade2)
+	if len(location1) == 0 || len(location2) == 0 {
+		return err
+	}
+	return fmt.Errorf("%s, found at %s and %s", err.Error(), location1, location2)
 }
 
-func verifyPairedTrades(trade1 *Trade, trade2 *Trade) error {
+// comparePairedTrades returns the differences of the two trades that are within tolerance
+func comparePairedTrades(trade1 *Trade, trade2 *Trade, options PairingOptions) ([]string, error) {
+	var differences []string
+
 	if trade1.PayOrReceive == trade2.PayOrReceive {
-		return fmt.Errorf("both trades with CCPTradeID=%s have PayOrReceive=%s",
+		return nil, fmt.Errorf("both trades with CCPTradeID=%s have PayOrReceive=%s",
 			trade1.CCPTradeID,
 			trade1.PayOrReceive)
 	}
 
-	if trade1.Notional != trade2.Notional {
-		return fmt.Errorf("trades with CCPTradeID=%s have different notionals: %d and %d",
-			trade1.CCPTradeID,
-			trade1.Notional,
-			trade2.Notional)
+	if trade1.Notional.Cmp(trade2.Notional) != 0 {
+		if !options.isNotionalWithinTolerance(trade1.Notional, trade2.Notional) {
+			return nil, fmt.Errorf("trades with CCPTradeID=%s have different notionals: %s and %s",
+				trade1.CCPTradeID,
+				trade1.Notional,
+				trade2.Notional)
+		}
+		differences = append(differences, fmt.Sprintf("different notionals: %s and %s", trade1.Notional, trade2.Notional))
 	}
 
 	if trade1.Currency != trade2.Currency {
-		return fmt.Errorf("trades with CCPTradeID=%s have different currency: %s and %s",
+		return nil, fmt.Errorf("trades with CCPTradeID=%s have different currency: %s and %s",
 			trade1.CCPTradeID,
 			trade1.Currency,
 			trade2.Currency)
 	}
 
-	if !trade1.MaturityDate.Equal(trade2.MaturityDate) {
-		return fmt.Errorf("trades with CCPTradeID=%s have different MaturityDate: %s and %s",
-			trade1.CCPTradeID,
-			trade1.MaturityDate.Format(DATE_FORMAT),
-			trade2.MaturityDate.Format(DATE_FORMAT))
+	if !trade1.AdjustedMaturityDate.Equal(trade2.AdjustedMaturityDate) {
+		if !options.isMaturityWithinTolerance(trade1.AdjustedMaturityDate, trade2.AdjustedMaturityDate) {
+			return nil, fmt.Errorf("trades with CCPTradeID=%s have different MaturityDate: %s and %s",
+				trade1.CCPTradeID,
+				formatMaturityDate(trade1),
+				formatMaturityDate(trade2))
+		}
+		differences = append(differences, fmt.Sprintf("different MaturityDate: %s and %s",
+			formatMaturityDate(trade1), formatMaturityDate(trade2)))
 	}
 
 	if trade1.Cpty != trade2.Party || trade2.Cpty != trade1.Party {
-		return fmt.Errorf("trades with CCPTradeID=%s counterparties do not match, trade1: Party=%s, Cpty=%s, trade2: Party=%s, Cpty=%s",
+		return nil, fmt.Errorf("trades with CCPTradeID=%s counterparties do not match, trade1: Party=%s, Cpty=%s, trade2: Party=%s, Cpty=%s",
 			trade1.CCPTradeID,
 			trade1.Party, trade1.Cpty,
 			trade2.Party, trade2.Cpty)
 	}
 
-	return nil
+	return differences, nil
 }
 
-func cleanRawTrade(rawTrade *RawTrade) (*Trade, error) {
+func cleanRawTrade(rawTrade *RawTrade, dateOptions DateOptions, allowedCurrencies map[string]bool) (*Trade, error) {
+	if len(rawTrade.ParseError) > 0 {
+		return nil, fmt.Errorf("%s", rawTrade.ParseError)
+	}
+
 	errors := make([]string, 0)
 	emptyColumns := make([]string, 0)
 
@@ -144,7 +388,7 @@ func cleanRawTrade(rawTrade *RawTrade) (*Trade, error) {
 		emptyColumns = append(emptyColumns, "TradeID")
 	}
 
-	currency := strings.TrimSpace(rawTrade.Currency)
+	currency := NormalizeCurrency(rawTrade.Currency)
 	if len(currency) == 0 {
 		emptyColumns = append(emptyColumns, "Currency")
 	}
@@ -154,10 +398,8 @@ func cleanRawTrade(rawTrade *RawTrade) (*Trade, error) {
 		emptyColumns = append(emptyColumns, "Cpty")
 	}
 
+	// trades without CCPTradeID are paired by their economic terms
 	ccpTradeID := strings.TrimSpace(rawTrade.CCPTradeID)
-	if len(ccpTradeID) == 0 {
-		emptyColumns = append(emptyColumns, "CCPTradeID")
-	}
 
 	if len(emptyColumns) == 1 {
 		errors = append(errors, fmt.Sprintf("%s is empty", emptyColumns[0]))
@@ -165,20 +407,28 @@ func cleanRawTrade(rawTrade *RawTrade) (*Trade, error) {
 		errors = append(errors, fmt.Sprintf("%s are empty", strings.Join(emptyColumns, ", ")))
 	}
 
-	notional, err := strconv.Atoi(rawTrade.Notional)
+	if len(currency) > 0 {
+		if err := validateCurrency(currency, allowedCurrencies); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+
+	notional, err := toolkit.ParseDecimal(rawTrade.Notional)
 	if err != nil {
-		errors = append(errors, fmt.Sprintf("Notional %s is not a valid integer", rawTrade.Notional))
-	} else if notional < 0 {
+		errors = append(errors, fmt.Sprintf("Notional %s", err.Error()))
+	} else if notional.Sign() < 0 {
 		errors = append(errors, fmt.Sprintf("Notional %s is a negative value", rawTrade.Notional))
+	} else if notional, err = notional.Rescale(GetMinorUnits(currency)); err != nil {
+		errors = append(errors, fmt.Sprintf("Notional %s", err.Error()))
 	}
 
 	if rawTrade.PayOrReceive != "P" && rawTrade.PayOrReceive != "R" {
 		errors = append(errors, fmt.Sprintf("PayOrReceive %s is neither 'P' or 'R'", rawTrade.PayOrReceive))
 	}
 
-	maturityDate, err := dateparse.ParseAny(rawTrade.MaturityDate)
+	maturityDate, err := dateOptions.parseMaturityDate(rawTrade.MaturityDate, dateOptions.getDateFormat(rawTrade, party))
 	if err != nil {
-		errors = append(errors, fmt.Sprintf("fail to parse MaturityDate %s, date is invalid", rawTrade.MaturityDate))
+		errors = append(errors, err.Error())
 	}
 
 	if len(errors) > 0 {
@@ -186,15 +436,20 @@ func cleanRawTrade(rawTrade *RawTrade) (*Trade, error) {
 	}
 
 	return &Trade{
-		Party:        party,
-		Book:         book,
-		TradeID:      tradeID,
-		PayOrReceive: rawTrade.PayOrReceive,
-		Currency:     rawTrade.Currency,
-		MaturityDate: maturityDate,
-		Cpty:         cPty,
-		CCPTradeID:   ccpTradeID,
-		Notional:     uint64(notional),
+		Party:             party,
+		Book:              book,
+		TradeID:           tradeID,
+		PayOrReceive:      rawTrade.PayOrReceive,
+		Currency:          currency,
+		MaturityDate:      maturityDate,
+		Cpty:              cPty,
+		CCPTradeID:        ccpTradeID,
+		Notional:          notional,
+		FileName:          rawTrade.FileName,
+		SheetName:         rawTrade.SheetName,
+		RowNumber:         rawTrade.RowNumber,
+		Attributes:        rawTrade.Attributes,
+		SubmittedNotional: notional,
 	}, nil
 }
 
@@ -209,7 +464,9 @@ func createExcludedTradeFromRawTrade(rawTrade *RawTrade, err error) *ExcludedTra
 		Cpty:         rawTrade.Cpty,
 		CCPTradeID:   rawTrade.CCPTradeID,
 		Notional:     rawTrade.Notional,
-		Error:        err.Error(),
+		Error:        addTradeLocation(rawTrade.SheetName, rawTrade.RowNumber, err.Error()),
+		FileName:     rawTrade.FileName,
+		RowNumber:    formatRowNumber(rawTrade.RowNumber),
 	}
 }
 
@@ -218,22 +475,61 @@ func createExcludedTradeFromCleanTrades(cleanTrades []*Trade, errorMessage strin
 
 	for i := 0; i < len(cleanTrades); i++ {
 		excludedTrades[i] = &ExcludedTrade{
-			Party:        cleanTrades[i].Party,
-			Book:         cleanTrades[i].Book,
-			TradeID:      cleanTrades[i].TradeID,
-			PayOrReceive: cleanTrades[i].PayOrReceive,
-			Currency:     cleanTrades[i].Currency,
-			MaturityDate: cleanTrades[i].MaturityDate.Format(DATE_FORMAT),
-			Cpty:         cleanTrades[i].Cpty,
-			CCPTradeID:   cleanTrades[i].CCPTradeID,
-			Notional:     fmt.Sprintf("%d", cleanTrades[i].Notional),
-			Error:        errorMessage,
+			Party:                cleanTrades[i].Party,
+			Book:                 cleanTrades[i].Book,
+			TradeID:              cleanTrades[i].TradeID,
+			PayOrReceive:         cleanTrades[i].PayOrReceive,
+			Currency:             cleanTrades[i].Currency,
+			MaturityDate:         cleanTrades[i].MaturityDate.Format(DATE_FORMAT),
+			AdjustedMaturityDate: cleanTrades[i].AdjustedMaturityDate.Format(DATE_FORMAT),
+			Cpty:                 cleanTrades[i].Cpty,
+			CCPTradeID:           cleanTrades[i].CCPTradeID,
+			Notional:             cleanTrades[i].Notional.String(),
+			Error:                addTradeLocation(cleanTrades[i].SheetName, cleanTrades[i].RowNumber, errorMessage),
+			Warning:              cleanTrades[i].Warning,
+			FileName:             cleanTrades[i].FileName,
+			RowNumber:            formatRowNumber(cleanTrades[i].RowNumber),
 		}
 	}
 	return excludedTrades
 }
 
-func (handler *MainHandler) GetExcludedTradesAsCSV() (string, error) {
+func formatRowNumber(rowNumber int) string {
+	if rowNumber <= 0 {
+		return ""
+	}
+	return strconv.Itoa(rowNumber)
+}
+
+// formatMaturityDate returns the maturity date of the trade, followed by its adjusted date when they differ
+func formatMaturityDate(trade *Trade) string {
+	maturityDate := trade.MaturityDate.Format(DATE_FORMAT)
+	adjustedMaturityDate := trade.SubmittedAdjustedMaturityDate.Format(DATE_FORMAT)
+	if maturityDate == adjustedMaturityDate {
+		return maturityDate
+	}
+	return fmt.Sprintf("%s adjusted to %s", maturityDate, adjustedMaturityDate)
+}
+
+// getTradeLocation returns where the trade was read from, like "trades.csv row 12", or "" when it is unknown
+func getTradeLocation(trade *Trade) string {
+	if len(trade.FileName) == 0 {
+		return ""
+	}
+	if len(trade.SheetName) > 0 {
+		return fmt.Sprintf("%s sheet %s row %d", trade.FileName, trade.SheetName, trade.RowNumber)
+	}
+	return fmt.Sprintf("%s row %d", trade.FileName, trade.RowNumber)
+}
+
+func addTradeLocation(sheetName string, rowNumber int, errorMessage string) string {
+	if len(sheetName) == 0 {
+		return errorMessage
+	}
+	return fmt.Sprintf("sheet %s row %d: %s", sheetName, rowNumber, errorMessage)
+}
+
+func (handler *MainHandler) GetExcludedTrades() []*ExcludedTrade {
 	excludedTrades := handler.PortfolioLoader.ExcludedTrades
 
 	sort.Slice(excludedTrades, func(i, j int) bool {
@@ -257,6 +553,12 @@ func (handler *MainHandler) GetExcludedTradesAsCSV() (string, error) {
 		return excludedTrades[i].TradeID < excludedTrades[j].TradeID
 	})
 
+	return excludedTrades
+}
+
+func (handler *MainHandler) GetExcludedTradesAsCSV() (string, error) {
+	excludedTrades := handler.GetExcludedTrades()
+
 	exclusion, err := gocsv.MarshalBytes(excludedTrades)
 	if err != nil {
 		return "", err
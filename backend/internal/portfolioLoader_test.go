@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLoadPortfolioWithCancelledContext(t *testing.T) {
+	rawTrades := []*RawTrade{
+		{Party: "A", Book: "1BKA", TradeID: "A1", PayOrReceive: "P", Currency: "EUR", MaturityDate: "2022/12/31",
+			Cpty: "D", CCPTradeID: "CCP1", Notional: "100000"},
+		{Party: "D", Book: "1BKD", TradeID: "D1", PayOrReceive: "R", Currency: "EUR", MaturityDate: "2022/12/31",
+			Cpty: "A", CCPTradeID: "CCP1", Notional: "100000"},
+	}
+
+	handler := NewMainHandler()
+	if err := handler.LoadPortfolio(context.Background(), rawTrades); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := handler.GenerateCompressionResults(ctx); err != context.Canceled {
+		t.Errorf("expected GenerateCompressionResults to stop with %v, got %v", context.Canceled, err)
+	}
+	if err := handler.GenerateBookLevelCompressionResults(ctx); err != context.Canceled {
+		t.Errorf("expected GenerateBookLevelCompressionResults to stop with %v, got %v", context.Canceled, err)
+	}
+	if err := NewMainHandler().LoadPortfolio(ctx, rawTrades); err != context.Canceled {
+		t.Errorf("expected LoadPortfolio to stop with %v, got %v", context.Canceled, err)
+	}
+}
+
+type testInputFile struct {
+	fileName string
+	content  string
+}
+
+// loadTestInputFiles loads the files, written without a header, and returns the excluded trades by TradeID
+func loadTestInputFiles(t *testing.T, handler *MainHandler, files []testInputFile) map[string]*ExcludedTrade {
+	t.Helper()
+
+	handler.StartLoadingPortfolio()
+	for _, file := range files {
+		if err := handler.LoadInputFile(file.fileName, InputFileOptions{}, strings.NewReader(TEST_INPUT_HEADER+file.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler.FinishLoadingPortfolio()
+
+	tradeIDToExcludedTrade := make(map[string]*ExcludedTrade)
+	for _, excludedTrade := range handler.PortfolioLoader.ExcludedTrades {
+		tradeIDToExcludedTrade[excludedTrade.TradeID] = excludedTrade
+	}
+	return tradeIDToExcludedTrade
+}
+
+func TestExcludedTradeSources(t *testing.T) {
+	tradeIDToExcludedTrade := loadTestInputFiles(t, NewMainHandler(), []testInputFile{
+		{"a.csv", "A,1BKA,A1,P,EUR,2022/12/31,D,CCP1,100000\n" +
+			"A,1BKA,A2,P,EUR,2022/12/31,D,CCP2,abc\n"},
+		{"d.csv", "D,1BKD,D1,R,EUR,2022/12/31,A,CCP1,120000\n"},
+	})
+
+	expected := []struct {
+		tradeID   string
+		fileName  string
+		rowNumber string
+		error     string
+	}{
+		{"A2", "a.csv", "3", "Notional abc"},
+		{"A1", "a.csv", "2", "found at a.csv row 2 and d.csv row 2"},
+		{"D1", "d.csv", "2", "found at a.csv row 2 and d.csv row 2"},
+	}
+	for _, test := range expected {
+		excludedTrade, ok := tradeIDToExcludedTrade[test.tradeID]
+		if !ok {
+			t.Errorf("expected %s to be excluded", test.tradeID)
+			continue
+		}
+		if excludedTrade.FileName != test.fileName || excludedTrade.RowNumber != test.rowNumber ||
+			!strings.Contains(excludedTrade.Error, test.error) {
+			t.Errorf("expected %s to be excluded from %s row %s with %q, got %+v", test.tradeID, test.fileName,
+				test.rowNumber, test.error, excludedTrade)
+		}
+	}
+}
+
+func TestCurrencies(t *testing.T) {
+	handler := NewMainHandler()
+	handler.AllowedCurrencies = map[string]bool{"USD": true, "EUR": true}
+	tradeIDToExcludedTrade := loadTestInputFiles(t, handler, []testInputFile{{"input.csv",
+		"A,1BKA,A1,P,usd,2022/12/31,D,CCP1,100000\n" +
+			"D,1BKD,D1,R, USD ,2022/12/31,A,CCP1,100000\n" +
+			"A,1BKA,A2,P,XYZ,2022/12/31,D,CCP2,100000\n" +
+			"A,1BKA,A3,P,JPY,2022/12/31,D,CCP3,100000\n" +
+			"A,1BKA,A4,P,,2022/12/31,D,CCP4,100000\n"}})
+
+	for _, tradeID := range []string{"A1", "D1"} {
+		if excludedTrade, ok := tradeIDToExcludedTrade[tradeID]; ok {
+			t.Errorf("expected %s to be paired after normalizing its currency, got %+v", tradeID, excludedTrade)
+		}
+	}
+
+	expected := map[string]string{
+		"A2": "Currency XYZ is not an ISO 4217 currency code",
+		"A3": "Currency JPY is not an allowed currency",
+		"A4": "Currency",
+	}
+	for tradeID, error := range expected {
+		excludedTrade, ok := tradeIDToExcludedTrade[tradeID]
+		if !ok || !strings.Contains(excludedTrade.Error, error) {
+			t.Errorf("expected %s to be excluded with %q, got %+v", tradeID, error, excludedTrade)
+		}
+	}
+}
+
+func TestDuplicateTradeIDs(t *testing.T) {
+	handler := NewMainHandler()
+	tradeIDToExcludedTrade := loadTestInputFiles(t, handler, []testInputFile{
+		{"a1.csv", "A,1BKA,A1,P,EUR,2022/12/31,D,CCP1,100000\n" +
+			"A,1BKA,X1,P,EUR,2022/12/31,D,CCP3,100000\n"},
+		{"a2.csv", "A,1BKA,A1,R,EUR,2022/12/31,D,CCP2,50000\n"},
+		{"d.csv", "D,1BKD,D1,R,EUR,2022/12/31,A,CCP1,100000\n" +
+			"D,1BKD,D2,P,EUR,2022/12/31,A,CCP2,50000\n" +
+			"D,1BKD,X1,R,EUR,2022/12/31,A,CCP3,100000\n"},
+	})
+
+	excludedTrade, ok := tradeIDToExcludedTrade["A1"]
+	if !ok || !strings.Contains(excludedTrade.Error, "duplicate TradeID A1 for party A") ||
+		!strings.Contains(excludedTrade.Error, "a1.csv row 2") || !strings.Contains(excludedTrade.Error, "a2.csv row 2") {
+		t.Errorf("expected A1 to be excluded as a duplicate naming both rows, got %+v", excludedTrade)
+	}
+	var noOfDuplicates int
+	for _, excludedTrade := range handler.PortfolioLoader.ExcludedTrades {
+		if excludedTrade.Party == "A" && excludedTrade.TradeID == "A1" {
+			noOfDuplicates++
+		}
+	}
+	if noOfDuplicates != 2 {
+		t.Errorf("expected both trades A1 to be excluded, got %d", noOfDuplicates)
+	}
+	for _, tradeID := range []string{"D1", "D2"} {
+		if excludedTrade, ok := tradeIDToExcludedTrade[tradeID]; !ok || !strings.Contains(excludedTrade.Error, "a duplicate TradeID") {
+			t.Errorf("expected %s to be excluded with its duplicate paired trade, got %+v", tradeID, excludedTrade)
+		}
+	}
+	if excludedTrade, ok := tradeIDToExcludedTrade["X1"]; ok {
+		t.Errorf("expected the same TradeID of two parties not to be a duplicate, got %+v", excludedTrade)
+	}
+}
@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type RawTradeReader struct {
+	csvReader         *csv.Reader
+	columnToField     map[int]int
+	columnToAttribute map[int]string
+	rowNumber         int
+}
+
+func NewRawTradeReader(in io.Reader, columnMapping ColumnMapping) (*RawTradeReader, error) {
+	csvReader := csv.NewReader(in)
+	csvReader.ReuseRecord = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("file is empty")
+		}
+		return nil, err
+	}
+
+	columnToField, columnToAttribute, err := getRawTradeColumnToField(header, columnMapping)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RawTradeReader{
+		csvReader:         csvReader,
+		columnToField:     columnToField,
+		columnToAttribute: columnToAttribute,
+		rowNumber:         1,
+	}, nil
+}
+
+func (reader *RawTradeReader) Read() (*RawTrade, error) {
+	record, err := reader.csvReader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	reader.rowNumber++
+	rawTrade := newRawTrade(reader.columnToField, record)
+	rawTrade.Attributes = newRawTradeAttributes(reader.columnToAttribute, record)
+	rawTrade.RowNumber = reader.rowNumber
+	return rawTrade, nil
+}
+
+// getRawTradeColumnToField matches the header to the columns of RawTrade ignoring case, a header given in the
+// column mapping wins over a header named like the column, which wins over a default alias. Other headers are
+// returned as attribute columns, except the ones that are empty or named like a column of the proposals.
+func getRawTradeColumnToField(header []string, columnMapping ColumnMapping) (map[int]int, map[int]string, error) {
+	rawTradeType := reflect.TypeOf(RawTrade{})
+	tagToField := make(map[string]int, rawTradeType.NumField())
+	for i := 0; i < rawTradeType.NumField(); i++ {
+		tag := rawTradeType.Field(i).Tag.Get("csv")
+		if len(tag) > 0 && tag != "-" {
+			tagToField[strings.ToLower(tag)] = i
+		}
+	}
+
+	aliasToField := make(map[string]int, len(columnMapping))
+	for alias, column := range columnMapping {
+		field, ok := tagToField[strings.ToLower(strings.TrimSpace(column))]
+		if !ok {
+			return nil, nil, fmt.Errorf("column mapping maps %s to unknown column %s", alias, column)
+		}
+		aliasToField[strings.ToLower(strings.TrimSpace(alias))] = field
+	}
+
+	defaultAliasToField := make(map[string]int, len(DEFAULT_COLUMN_ALIASES))
+	for alias, column := range DEFAULT_COLUMN_ALIASES {
+		defaultAliasToField[strings.ToLower(alias)] = tagToField[strings.ToLower(column)]
+	}
+
+	columnToField := make(map[int]int, len(tagToField))
+	fieldToColumn := make(map[int]int, len(tagToField))
+	fieldToPriority := make(map[int]int, len(tagToField))
+	columnToAttribute := make(map[int]string)
+	for column, headerName := range header {
+		headerName = strings.TrimSpace(strings.TrimPrefix(headerName, "\ufeff"))
+		name := strings.ToLower(headerName)
+
+		var field, priority int
+		var ok bool
+		if field, ok = aliasToField[name]; ok {
+			priority = 0
+		} else if field, ok = tagToField[name]; ok {
+			priority = 1
+		} else if field, ok = defaultAliasToField[name]; ok {
+			priority = 2
+		} else {
+			if len(headerName) > 0 && !isProposalColumn(headerName) {
+				columnToAttribute[column] = headerName
+			}
+			continue
+		}
+
+		if existingPriority, mapped := fieldToPriority[field]; mapped {
+			if existingPriority <= priority {
+				continue
+			}
+			delete(columnToField, fieldToColumn[field])
+		}
+		columnToField[column] = field
+		fieldToColumn[field] = column
+		fieldToPriority[field] = priority
+	}
+
+	if len(fieldToColumn) < len(tagToField) {
+		missingColumns := make([]string, 0, len(tagToField)-len(fieldToColumn))
+		for i := 0; i < rawTradeType.NumField(); i++ {
+			tag := rawTradeType.Field(i).Tag.Get("csv")
+			if _, mapped := fieldToColumn[i]; !mapped && len(tag) > 0 && tag != "-" {
+				missingColumns = append(missingColumns, tag)
+			}
+		}
+		sort.Strings(missingColumns)
+		return nil, nil, fmt.Errorf("found unmatched struct field with tags %v", missingColumns)
+	}
+
+	return columnToField, columnToAttribute, nil
+}
+
+func newRawTrade(columnToField map[int]int, record []string) *RawTrade {
+	rawTrade := &RawTrade{}
+	rawTradeValue := reflect.ValueOf(rawTrade).Elem()
+	for column, field := range columnToField {
+		if column < len(record) {
+			rawTradeValue.Field(field).SetString(record[column])
+		}
+	}
+
+	return rawTrade
+}
+
+func newRawTradeAttributes(columnToAttribute map[int]string, record []string) map[string]string {
+	if len(columnToAttribute) == 0 {
+		return nil
+	}
+
+	attributes := make(map[string]string, len(columnToAttribute))
+	for column, attribute := range columnToAttribute {
+		if column < len(record) {
+			attributes[attribute] = record[column]
+		} else {
+			attributes[attribute] = ""
+		}
+	}
+
+	return attributes
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"github.com/zytan787/code-to-connect-2021/api"
+	"strings"
+	"testing"
+)
+
+func TestRawTradeReaderColumnMapping(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		columnMapping ColumnMapping
+		expected      RawTrade
+		attributes    map[string]string
+		error         string
+	}{
+		{"default aliases", "Party,Book,TradeID,Direction,Ccy,Maturity,Cpty,ClearingID,Notional\n" +
+			"A,1BKA,A1,P,EUR,2022/12/31,D,CCP1,100\n", nil,
+			RawTrade{Party: "A", Book: "1BKA", TradeID: "A1", PayOrReceive: "P", Currency: "EUR",
+				MaturityDate: "2022/12/31", Cpty: "D", CCPTradeID: "CCP1", Notional: "100"}, nil, ""},
+		{"headers in another case", "PARTY,book,tradeid,Pay/Receive,CURRENCY,maturitydate,cpty,ccptradeid,NOTIONAL\n" +
+			"A,1BKA,A1,P,EUR,2022/12/31,D,CCP1,100\n", nil,
+			RawTrade{Party: "A", Book: "1BKA", TradeID: "A1", PayOrReceive: "P", Currency: "EUR",
+				MaturityDate: "2022/12/31", Cpty: "D", CCPTradeID: "CCP1", Notional: "100"}, nil, ""},
+		{"column mapping over a header named like the column", "Party,Book,TradeID,Side,Currency,MaturityDate,Cpty,CCPTradeID,Notional,PAY/RECEIVE\n" +
+			"A,1BKA,A1,P,EUR,2022/12/31,D,CCP1,100,R\n", ColumnMapping{"side": "pay/receive"},
+			RawTrade{Party: "A", Book: "1BKA", TradeID: "A1", PayOrReceive: "P", Currency: "EUR",
+				MaturityDate: "2022/12/31", Cpty: "D", CCPTradeID: "CCP1", Notional: "100"}, nil, ""},
+		{"header named like the column over a default alias", "Party,Book,TradeID,PAY/RECEIVE,Currency,Ccy,MaturityDate,Cpty,CCPTradeID,Notional\n" +
+			"A,1BKA,A1,P,EUR,USD,2022/12/31,D,CCP1,100\n", nil,
+			RawTrade{Party: "A", Book: "1BKA", TradeID: "A1", PayOrReceive: "P", Currency: "EUR",
+				MaturityDate: "2022/12/31", Cpty: "D", CCPTradeID: "CCP1", Notional: "100"}, nil, ""},
+		{"extra columns", "Party,Book,TradeID,PAY/RECEIVE,Currency,MaturityDate,Cpty,CCPTradeID,Notional,Desk,,Action\n" +
+			"A,1BKA,A1,P,EUR,2022/12/31,D,CCP1,100,Rates,x,CXL\n", nil,
+			RawTrade{Party: "A", Book: "1BKA", TradeID: "A1", PayOrReceive: "P", Currency: "EUR",
+				MaturityDate: "2022/12/31", Cpty: "D", CCPTradeID: "CCP1", Notional: "100"},
+			map[string]string{"Desk": "Rates"}, ""},
+		{"missing column", "Party,Book,TradeID,PAY/RECEIVE,Currency,MaturityDate,Cpty,Notional\n", nil,
+			RawTrade{}, nil, "found unmatched struct field with tags [CCPTradeID]"},
+		{"mapping to an unknown column", TEST_INPUT_FILE, ColumnMapping{"Desk": "Trader"},
+			RawTrade{}, nil, "column mapping maps Desk to unknown column Trader"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader, err := NewRawTradeReader(strings.NewReader(test.content), test.columnMapping)
+			if len(test.error) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.error) {
+					t.Fatalf("expected error %q, got %v", test.error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rawTrade, err := reader.Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rawTrade.Party != test.expected.Party || rawTrade.Book != test.expected.Book ||
+				rawTrade.TradeID != test.expected.TradeID || rawTrade.PayOrReceive != test.expected.PayOrReceive ||
+				rawTrade.Currency != test.expected.Currency || rawTrade.MaturityDate != test.expected.MaturityDate ||
+				rawTrade.Cpty != test.expected.Cpty || rawTrade.CCPTradeID != test.expected.CCPTradeID ||
+				rawTrade.Notional != test.expected.Notional || rawTrade.RowNumber != 2 {
+				t.Errorf("expected %+v, got %+v", test.expected, rawTrade)
+			}
+			if len(rawTrade.Attributes) != len(test.attributes) {
+				t.Errorf("expected the attributes %v, got %v", test.attributes, rawTrade.Attributes)
+			}
+			for attribute, value := range test.attributes {
+				if rawTrade.Attributes[attribute] != value {
+					t.Errorf("expected the attributes %v, got %v", test.attributes, rawTrade.Attributes)
+				}
+			}
+		})
+	}
+}
+
+func TestGetInputFileOptions(t *testing.T) {
+	handler := NewMainHandler()
+	handler.ColumnMapping = ColumnMapping{"Side": "PAY/RECEIVE", "Ref": "TradeID"}
+	handler.ColumnMappingProfiles = ColumnMappingProfiles{"B": {"Ref": "CCPTradeID"}}
+
+	options, err := handler.getInputFileOptions(api.File{FileName: "b.csv", ColumnMappingProfile: "B"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.ColumnMapping["Side"] != "PAY/RECEIVE" || options.ColumnMapping["Ref"] != "CCPTradeID" {
+		t.Errorf("expected the profile to win over the request, got %v", options.ColumnMapping)
+	}
+	if handler.ColumnMapping["Ref"] != "TradeID" {
+		t.Errorf("expected the column mapping of the request to be kept, got %v", handler.ColumnMapping)
+	}
+
+	if _, err = handler.getInputFileOptions(api.File{FileName: "c.csv", ColumnMappingProfile: "C"}); err == nil ||
+		!strings.Contains(err.Error(), "column mapping profile C of file c.csv not found") {
+		t.Errorf("expected an unknown profile to fail, got %v", err)
+	}
+}
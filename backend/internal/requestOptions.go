@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/zytan787/code-to-connect-2021/api"
+	"path/filepath"
+	"strings"
+)
+
+// RequestOptions are the options of a compression request that change its results, parsed from api.CompressTradesReq
+type RequestOptions struct {
+	ColumnMapping         ColumnMapping
+	AttributeRule         AttributeRule
+	DateOptions           DateOptions
+	TenorOptions          TenorOptions
+	BusinessDayConvention BusinessDayConvention
+	PairingOptions        PairingOptions
+}
+
+func parseRequestOptions(req api.CompressTradesReq) (RequestOptions, error) {
+	options := RequestOptions{
+		ColumnMapping: req.ColumnMapping,
+	}
+	var err error
+
+	if options.AttributeRule, err = ParseAttributeRule(req.AttributeRule); err != nil {
+		return options, err
+	}
+	if options.DateOptions, err = ParseDateOptions(req.PartyDateFormats, req.StrictDates); err != nil {
+		return options, err
+	}
+	if options.TenorOptions, err = ParseTenorOptions(req.AsOfDate, req.MinResidualBusinessDays); err != nil {
+		return options, err
+	}
+	if options.BusinessDayConvention, err = ParseBusinessDayConvention(req.BusinessDayConvention); err != nil {
+		return options, err
+	}
+	options.PairingOptions, err = ParsePairingOptions(req.NotionalTolerance.String(), req.RelativeNotionalTolerance.String(),
+		req.MaturityToleranceDays, req.CanonicalSide)
+	return options, err
+}
+
+func (handler *MainHandler) setRequestOptions(options RequestOptions) {
+	handler.ColumnMapping = options.ColumnMapping
+	handler.AttributeRule = options.AttributeRule
+	handler.DateOptions = options.DateOptions
+	handler.TenorOptions = options.TenorOptions
+	handler.BusinessDayConvention = options.BusinessDayConvention
+	handler.PairingOptions = options.PairingOptions
+}
+
+// inputSettings is the canonical encoding of everything besides the content of the input files that changes the
+// results of a run, json.Marshal writes the keys of maps sorted and decimals are written as reduced fractions
+type inputSettings struct {
+	ColumnMapping             ColumnMapping         `json:"column_mapping,omitempty"`
+	AttributeRule             AttributeRule         `json:"attribute_rule"`
+	PartyDateFormats          map[string]DateFormat `json:"party_date_formats,omitempty"`
+	StrictDates               bool                  `json:"strict_dates"`
+	AsOfDate                  string                `json:"as_of_date,omitempty"`
+	MinResidualBusinessDays   int                   `json:"min_residual_business_days"`
+	BusinessDayConvention     BusinessDayConvention `json:"business_day_convention"`
+	NotionalTolerance         string                `json:"notional_tolerance"`
+	RelativeNotionalTolerance string                `json:"relative_notional_tolerance"`
+	MaturityToleranceDays     int                   `json:"maturity_tolerance_days"`
+	CanonicalSide             string                `json:"canonical_side"`
+	InputFiles                []inputFileSettings   `json:"input_files,omitempty"`
+	AllowedCurrencies         map[string]bool       `json:"allowed_currencies,omitempty"`
+	HolidayCalendars          HolidayCalendars      `json:"holiday_calendars,omitempty"`
+}
+
+type inputFileSettings struct {
+	FileName             string        `json:"file_name"`
+	ColumnMappingProfile string        `json:"column_mapping_profile,omitempty"`
+	ColumnMapping        ColumnMapping `json:"column_mapping,omitempty"`
+	DateFormat           string        `json:"date_format,omitempty"`
+}
+
+// encodeInputSettings encodes the options of the handler, the column mapping profiles and date formats chosen
+// by the input files and the deployment settings, so that a run is only replayed for the same settings
+func (handler *MainHandler) encodeInputSettings(inputFiles []api.File) ([]byte, error) {
+	settings := inputSettings{
+		ColumnMapping:             handler.ColumnMapping,
+		AttributeRule:             handler.AttributeRule,
+		PartyDateFormats:          handler.DateOptions.PartyDateFormats,
+		StrictDates:               handler.DateOptions.Strict,
+		MinResidualBusinessDays:   handler.TenorOptions.MinResidualBusinessDays,
+		BusinessDayConvention:     handler.BusinessDayConvention,
+		NotionalTolerance:         handler.PairingOptions.NotionalTolerance.Rat().RatString(),
+		RelativeNotionalTolerance: handler.PairingOptions.RelativeNotionalTolerance.Rat().RatString(),
+		MaturityToleranceDays:     handler.PairingOptions.MaturityToleranceDays,
+		CanonicalSide:             handler.PairingOptions.CanonicalSide,
+		AllowedCurrencies:         handler.AllowedCurrencies,
+		HolidayCalendars:          handler.HolidayCalendars,
+	}
+	if !handler.TenorOptions.AsOfDate.IsZero() {
+		settings.AsOfDate = handler.TenorOptions.AsOfDate.Format(DATE_FORMAT)
+	}
+
+	for _, inputFile := range inputFiles {
+		if len(inputFile.ColumnMappingProfile) == 0 && len(inputFile.DateFormat) == 0 {
+			continue
+		}
+		settings.InputFiles = append(settings.InputFiles, inputFileSettings{
+			FileName:             filepath.Base(inputFile.FileName),
+			ColumnMappingProfile: inputFile.ColumnMappingProfile,
+			ColumnMapping:        handler.ColumnMappingProfiles[inputFile.ColumnMappingProfile],
+			DateFormat:           strings.ToUpper(strings.TrimSpace(inputFile.DateFormat)),
+		})
+	}
+
+	return json.Marshal(settings)
+}
+
+func (handler *MainHandler) newInputHasher(inputFiles []api.File) (*InputHasher, error) {
+	settings, err := handler.encodeInputSettings(inputFiles)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode the input settings due to: %s", err.Error())
+	}
+	return NewInputHasher(settings), nil
+}
+
+// hashInputFiles hashes the input files of a request together with the settings of the handler
+func (handler *MainHandler) hashInputFiles(inputFiles []api.File) (string, error) {
+	inputHasher, err := handler.newInputHasher(inputFiles)
+	if err != nil {
+		return "", err
+	}
+	for _, inputFile := range inputFiles {
+		err = inputHasher.AddFile(inputFile.FileName, inputFile.SheetName,
+			base64.NewDecoder(base64.StdEncoding, strings.NewReader(inputFile.FileContent)))
+		if err != nil {
+			return "", fmt.Errorf("unable to decode the base64 string of file %s due to: %s", inputFile.FileName, err.Error())
+		}
+	}
+	return inputHasher.Sum(), nil
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/sirupsen/logrus"
+	"github.com/zytan787/code-to-connect-2021/api"
+	"net/http"
+	"testing"
+)
+
+const TEST_INPUT_FILE = `Party,Book,TradeID,PAY/RECEIVE,Currency,MaturityDate,Cpty,CCPTradeID,Notional
+A,1BKA,A0,R,EUR,2022/12/31,D,CCP0,467749
+D,1BKD,D0,P,EUR,2022/12/31,A,CCP0,467749
+A,1BKA,A1,P,EUR,2022/12/31,D,CCP1,100000
+D,1BKD,D1,R,EUR,2022/12/31,A,CCP1,100000
+`
+
+func runTestRequest(t *testing.T, runStore *RunStore, req api.CompressTradesReq) (int, *api.CompressTradesResp) {
+	t.Helper()
+
+	options, err := parseRequestOptions(req)
+	if err != nil {
+		t.Fatalf("parseRequestOptions returned %s", err.Error())
+	}
+
+	handler := NewMainHandler()
+	handler.RunStore = runStore
+	handler.setRequestOptions(options)
+
+	resp := &api.CompressTradesResp{RequestID: req.RequestID}
+	statusCode := handler.runCompression(req.InputFiles, resp, logrus.WithFields(logrus.Fields{}))
+	return statusCode, resp
+}
+
+func TestResubmittedRequestID(t *testing.T) {
+	inputFiles := []api.File{{
+		FileName:    "input.csv",
+		FileContent: base64.StdEncoding.EncodeToString([]byte(TEST_INPUT_FILE)),
+	}}
+	otherInputFiles := []api.File{{
+		FileName:    "input.csv",
+		FileContent: base64.StdEncoding.EncodeToString([]byte(TEST_INPUT_FILE + "A,1BKA,A2,P,EUR,2022/12/31,D,CCP2,1\n")),
+	}}
+
+	tests := []struct {
+		name       string
+		req        api.CompressTradesReq
+		statusCode int
+	}{
+		{"same input files and options", api.CompressTradesReq{}, http.StatusOK},
+		{"same options written differently", api.CompressTradesReq{
+			AttributeRule:         "LARGEST_CANCELLED",
+			BusinessDayConvention: "None",
+			NotionalTolerance:     json.Number("0.00"),
+			CanonicalSide:         "Pay",
+		}, http.StatusOK},
+		{"different input files", api.CompressTradesReq{InputFiles: otherInputFiles}, http.StatusConflict},
+		{"different column_mapping", api.CompressTradesReq{ColumnMapping: map[string]string{"Ref": "TradeID"}}, http.StatusConflict},
+		{"different attribute_rule", api.CompressTradesReq{AttributeRule: "common"}, http.StatusConflict},
+		{"different party_date_formats", api.CompressTradesReq{PartyDateFormats: map[string]string{"A": "DMY"}}, http.StatusConflict},
+		{"different strict_dates", api.CompressTradesReq{StrictDates: true}, http.StatusConflict},
+		{"different as_of_date", api.CompressTradesReq{AsOfDate: "2022/01/01"}, http.StatusConflict},
+		{"different business_day_convention", api.CompressTradesReq{BusinessDayConvention: "following"}, http.StatusConflict},
+		{"different notional_tolerance", api.CompressTradesReq{NotionalTolerance: json.Number("1")}, http.StatusConflict},
+		{"different relative_notional_tolerance", api.CompressTradesReq{RelativeNotionalTolerance: json.Number("0.01")}, http.StatusConflict},
+		{"different maturity_tolerance_days", api.CompressTradesReq{MaturityToleranceDays: 1}, http.StatusConflict},
+		{"different canonical_side", api.CompressTradesReq{CanonicalSide: "receive"}, http.StatusConflict},
+		{"different date_format of a file", api.CompressTradesReq{InputFiles: []api.File{{
+			FileName:    inputFiles[0].FileName,
+			FileContent: inputFiles[0].FileContent,
+			DateFormat:  "ISO",
+		}}}, http.StatusConflict},
+	}
+
+	runStore, err := NewRunStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	statusCode, firstResp := runTestRequest(t, runStore, api.CompressTradesReq{RequestID: "run", InputFiles: inputFiles})
+	if statusCode != http.StatusOK {
+		t.Fatalf("first run returned %d: %s", statusCode, firstResp.Error)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.req.RequestID = "run"
+			if test.req.InputFiles == nil {
+				test.req.InputFiles = inputFiles
+			}
+
+			statusCode, resp := runTestRequest(t, runStore, test.req)
+			if statusCode != test.statusCode {
+				t.Fatalf("expected status %d, got %d: %s", test.statusCode, statusCode, resp.Error)
+			}
+			if statusCode == http.StatusOK && resp.CompressionReport != firstResp.CompressionReport {
+				t.Errorf("expected the stored run to be returned")
+			}
+		})
+	}
+}
+
+func TestResubmittedRequestIDWithChangedSettings(t *testing.T) {
+	inputFiles := []api.File{{
+		FileName:    "input.csv",
+		FileContent: base64.StdEncoding.EncodeToString([]byte(TEST_INPUT_FILE)),
+	}}
+
+	runStore, err := NewRunStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewMainHandler()
+	handler.RunStore = runStore
+	resp := &api.CompressTradesResp{RequestID: "run"}
+	if statusCode := handler.runCompression(inputFiles, resp, logrus.WithFields(logrus.Fields{})); statusCode != http.StatusOK {
+		t.Fatalf("first run returned %d: %s", statusCode, resp.Error)
+	}
+
+	handler = NewMainHandler()
+	handler.RunStore = runStore
+	handler.AllowedCurrencies = map[string]bool{"EUR": true}
+	resp = &api.CompressTradesResp{RequestID: "run"}
+	if statusCode := handler.runCompression(inputFiles, resp, logrus.WithFields(logrus.Fields{})); statusCode != http.StatusConflict {
+		t.Fatalf("expected status %d after changing the allowed currencies, got %d", http.StatusConflict, statusCode)
+	}
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TenorOptions excludes the trades that have matured on the as-of date or mature within
+// MinResidualBusinessDays business days after it, nothing is excluded when AsOfDate is zero
+type TenorOptions struct {
+	AsOfDate                time.Time
+	MinResidualBusinessDays int
+}
+
+// ParseTenorOptions reads an as-of date written year first, like 2025/04/03 or 2025-04-03
+func ParseTenorOptions(asOfDate string, minResidualBusinessDays int) (TenorOptions, error) {
+	var options TenorOptions
+
+	if minResidualBusinessDays < 0 {
+		return options, fmt.Errorf("min_residual_business_days %d is a negative value", minResidualBusinessDays)
+	}
+	options.MinResidualBusinessDays = minResidualBusinessDays
+
+	asOfDate = strings.TrimSpace(asOfDate)
+	if len(asOfDate) == 0 {
+		if minResidualBusinessDays > 0 {
+			return options, fmt.Errorf("min_residual_business_days is given without an as_of_date")
+		}
+		return options, nil
+	}
+
+	match := yearFirstDatePattern.FindStringSubmatch(asOfDate)
+	if match == nil || len(match[1]) == 0 {
+		return options, fmt.Errorf("as_of_date %s is not a date like YYYY/MM/DD", asOfDate)
+	}
+	date, err := newDate(match[1], match[2], match[3], fmt.Errorf("as_of_date %s is not a valid date", asOfDate))
+	if err != nil {
+		return options, err
+	}
+	options.AsOfDate = date
+	return options, nil
+}
+
+// checkResidualTenor returns an error when the trade has matured or matures too soon to be compressed,
+// going by its adjusted maturity date and the business days of its currency
+func (options TenorOptions) checkResidualTenor(trade *Trade, calendars HolidayCalendars) error {
+	if options.AsOfDate.IsZero() {
+		return nil
+	}
+
+	asOfDate := options.AsOfDate.Format(DATE_FORMAT)
+	if !trade.AdjustedMaturityDate.After(options.AsOfDate) {
+		return fmt.Errorf("trade has matured, MaturityDate %s is not after as-of date %s",
+			formatMaturityDate(trade), asOfDate)
+	}
+
+	minMaturityDate, err := calendars.addBusinessDays(options.AsOfDate, options.MinResidualBusinessDays, trade.Currency)
+	if err != nil {
+		return err
+	}
+	if !trade.AdjustedMaturityDate.After(minMaturityDate) {
+		return fmt.Errorf("MaturityDate %s is within %d business days of as-of date %s",
+			formatMaturityDate(trade), options.MinResidualBusinessDays, asOfDate)
+	}
+	return nil
+}
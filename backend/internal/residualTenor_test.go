@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTenorOptions(t *testing.T) {
+	tests := []struct {
+		name                    string
+		asOfDate                string
+		minResidualBusinessDays int
+		expected                string
+		error                   string
+	}{
+		{"no as-of date", "", 0, "", ""},
+		{"slashes", "2022/12/23", 5, "2022/12/23", ""},
+		{"dashes", " 2022-12-23 ", 0, "2022/12/23", ""},
+		{"negative business days", "2022/12/23", -1, "", "min_residual_business_days -1 is a negative value"},
+		{"business days without an as-of date", "", 5, "", "min_residual_business_days is given without an as_of_date"},
+		{"day first", "23/12/2022", 0, "", "as_of_date 23/12/2022 is not a date like YYYY/MM/DD"},
+		{"invalid date", "2022/02/30", 0, "", "as_of_date 2022/02/30 is not a valid date"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options, err := ParseTenorOptions(test.asOfDate, test.minResidualBusinessDays)
+			if len(test.error) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.error) {
+					t.Fatalf("expected error %q, got %v", test.error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if options.MinResidualBusinessDays != test.minResidualBusinessDays {
+				t.Errorf("expected %d business days, got %d", test.minResidualBusinessDays, options.MinResidualBusinessDays)
+			}
+			if len(test.expected) == 0 {
+				if !options.AsOfDate.IsZero() {
+					t.Errorf("expected no as-of date, got %s", options.AsOfDate.Format(DATE_FORMAT))
+				}
+			} else if options.AsOfDate.Format(DATE_FORMAT) != test.expected {
+				t.Errorf("expected as-of date %s, got %s", test.expected, options.AsOfDate.Format(DATE_FORMAT))
+			}
+		})
+	}
+}
+
+func TestResidualTenorExclusions(t *testing.T) {
+	tenorOptions, err := ParseTenorOptions("2022/12/23", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewMainHandler()
+	handler.TenorOptions = tenorOptions
+	handler.HolidayCalendars = HolidayCalendars{"USD": {"2022/12/26": true}}
+
+	// 5 business days after Friday 2022/12/23 is 2022/12/30 in EUR, and 2023/01/02 in USD after the holiday
+	tradeIDToExcludedTrade := loadTestInputFiles(t, handler, []testInputFile{{"input.csv",
+		"A,1BKA,A1,P,EUR,2022/12/23,D,CCP1,100000\n" +
+			"D,1BKD,D1,R,EUR,2022/12/23,A,CCP1,100000\n" +
+			"A,1BKA,A2,P,EUR,2022/12/30,D,CCP2,100000\n" +
+			"D,1BKD,D2,R,EUR,2022/12/30,A,CCP2,100000\n" +
+			"A,1BKA,A3,P,EUR,2023/01/02,D,CCP3,100000\n" +
+			"D,1BKD,D3,R,EUR,2023/01/02,A,CCP3,100000\n" +
+			"A,1BKA,A4,P,USD,2023/01/02,D,CCP4,100000\n" +
+			"D,1BKD,D4,R,USD,2023/01/02,A,CCP4,100000\n"}})
+
+	expected := map[string]string{
+		"A1": "trade has matured, MaturityDate 2022/12/23 is not after as-of date 2022/12/23",
+		"D1": "trade has matured",
+		"A2": "MaturityDate 2022/12/30 is within 5 business days of as-of date 2022/12/23",
+		"D2": "within 5 business days",
+		"A4": "MaturityDate 2023/01/02 is within 5 business days of as-of date 2022/12/23",
+		"D4": "within 5 business days",
+	}
+	for tradeID, error := range expected {
+		excludedTrade, ok := tradeIDToExcludedTrade[tradeID]
+		if !ok || !strings.Contains(excludedTrade.Error, error) {
+			t.Errorf("expected %s to be excluded with %q, got %+v", tradeID, error, excludedTrade)
+		}
+	}
+	for _, tradeID := range []string{"A3", "D3"} {
+		if excludedTrade, ok := tradeIDToExcludedTrade[tradeID]; ok {
+			t.Errorf("expected %s to be kept, got %+v", tradeID, excludedTrade)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/zytan787/code-to-connect-2021/api"
+	"io"
+	"net/http"
+	"sort"
+)
+
+const BUNDLE_MANIFEST_FILE = "manifest.json"
+
+type bundleFile struct {
+	fileName string
+	content  []byte
+}
+
+// runBundle holds the decoded files of a run and their manifest, everything that can fail before the ZIP is written
+type runBundle struct {
+	files    []bundleFile
+	manifest []byte
+}
+
+func WriteRunBundle(w io.Writer, resp *api.CompressTradesResp) error {
+	bundle, err := newRunBundle(resp)
+	if err != nil {
+		return err
+	}
+	return bundle.write(w)
+}
+
+func newRunBundle(resp *api.CompressTradesResp) (*runBundle, error) {
+	proposals := make([]api.Proposal, len(resp.Proposals))
+	copy(proposals, resp.Proposals)
+	sort.Slice(proposals, func(i, j int) bool {
+		return proposals[i].Party < proposals[j].Party
+	})
+
+	// the base64 content of each file by file name
+	encodedFiles := [][2]string{
+		{EXCLUSION_FILE, resp.Exclusion},
+		{COMPRESSION_REPORT_FILE, resp.CompressionReport},
+		{COMPRESSION_REPORT_BOOK_LEVEL_FILE, resp.CompressionReportBookLevel},
+		{DATA_CHECK_FILE, resp.DataCheck},
+		{BREAK_REPORT_FILE, resp.BreakReport},
+	}
+	for _, proposal := range proposals {
+		encodedFiles = append(encodedFiles, [2]string{GetProposalsFileName(proposal.Party), proposal.Proposal})
+	}
+
+	bundle := &runBundle{files: make([]bundleFile, 0, len(encodedFiles))}
+	manifest := api.BundleManifest{
+		RequestID: resp.RequestID,
+		Files:     make([]api.BundleFile, 0, len(encodedFiles)),
+	}
+
+	for _, encodedFile := range encodedFiles {
+		fileName := encodedFile[0]
+		content, err := base64.StdEncoding.DecodeString(encodedFile[1])
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode the base64 string of %s due to: %s", fileName, err.Error())
+		}
+
+		rows, err := countCSVRows(content)
+		if err != nil {
+			return nil, fmt.Errorf("unable to count the rows of %s due to: %s", fileName, err.Error())
+		}
+
+		hash := sha256.Sum256(content)
+		bundle.files = append(bundle.files, bundleFile{fileName: fileName, content: content})
+		manifest.Files = append(manifest.Files, api.BundleFile{
+			FileName: fileName,
+			Rows:     rows,
+			SHA256:   hex.EncodeToString(hash[:]),
+		})
+	}
+
+	var err error
+	if bundle.manifest, err = json.MarshalIndent(manifest, "", "  "); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+func (bundle *runBundle) write(w io.Writer) error {
+	zipWriter := zip.NewWriter(w)
+	for _, file := range bundle.files {
+		if err := writeZipFile(zipWriter, file.fileName, file.content); err != nil {
+			return err
+		}
+	}
+	if err := writeZipFile(zipWriter, BUNDLE_MANIFEST_FILE, bundle.manifest); err != nil {
+		return err
+	}
+	return zipWriter.Close()
+}
+
+func writeZipFile(zipWriter *zip.Writer, fileName string, content []byte) error {
+	fileWriter, err := zipWriter.Create(fileName)
+	if err != nil {
+		return err
+	}
+	_, err = fileWriter.Write(content)
+	return err
+}
+
+func countCSVRows(content []byte) (int, error) {
+	csvReader := csv.NewReader(bytes.NewReader(content))
+	csvReader.FieldsPerRecord = -1
+	csvReader.ReuseRecord = true
+
+	rows := 0
+	for {
+		_, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		rows++
+	}
+
+	// the header is not a row
+	if rows > 0 {
+		rows--
+	}
+	return rows, nil
+}
+
+func (store *RunStore) GetRunBundle(c *gin.Context) {
+	resp, ok := store.loadRequestedRun(c)
+	if !ok {
+		return
+	}
+
+	bundle, err := newRunBundle(resp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, api.CompressTradesResp{
+			RequestID: resp.RequestID,
+			Error:     fmt.Sprintf("Error in newRunBundle due to: %s", err.Error()),
+		})
+		return
+	}
+
+	streamAttachment(c, resp.RequestID, "application/zip", fmt.Sprintf("%s.zip", resp.RequestID), "WriteRunBundle", bundle.write)
+}
+
+// streamAttachment writes the file straight to the client, every check that can fail is done before it is called.
+// Once the headers are sent an error can no longer be answered with a 500, the connection is closed instead so
+// that the client gets a truncated response rather than a file that looks complete.
+func streamAttachment(c *gin.Context, requestID string, contentType string, fileName string, writerName string,
+	write func(w io.Writer) error) {
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+	c.Header("Content-Type", contentType)
+	c.Status(http.StatusOK)
+
+	err := write(c.Writer)
+	if err == nil {
+		return
+	}
+	if !c.Writer.Written() {
+		c.Header("Content-Disposition", "")
+		c.JSON(http.StatusInternalServerError, api.CompressTradesResp{
+			RequestID: requestID,
+			Error:     fmt.Sprintf("Error in %s due to: %s", writerName, err.Error()),
+		})
+		return
+	}
+	logrus.Errorf("Error in %s of request %s due to: %s", writerName, requestID, err.Error())
+	abortConnection(c)
+}
+
+// abortConnection closes the connection without ending the response
+func abortConnection(c *gin.Context) {
+	c.Abort()
+	conn, _, err := c.Writer.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	conn.Close()
+}
@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/zytan787/code-to-connect-2021/api"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func encodeTestFile(content string) string {
+	return base64.StdEncoding.EncodeToString([]byte(content))
+}
+
+func TestWriteRunBundle(t *testing.T) {
+	resp := &api.CompressTradesResp{
+		RequestID:                  "run",
+		Exclusion:                  encodeTestFile("Party,Error\nA,\"invalid, notional\"\nB,\"two\nlines\"\n"),
+		CompressionReport:          encodeTestFile("Party,Notional\nA,100\n"),
+		CompressionReportBookLevel: encodeTestFile("Party,Notional\n"),
+		DataCheck:                  encodeTestFile(""),
+		BreakReport:                encodeTestFile("Party,Rank\nA,1\nA,2\nA,3\n"),
+		Proposals: []api.Proposal{
+			{Party: "B", Proposal: encodeTestFile("Party,TradeID\nB,U1\n")},
+			{Party: "A", Proposal: encodeTestFile("Party,TradeID\nA,T1\nA,T2\n")},
+		},
+	}
+	expectedRows := map[string]int{
+		EXCLUSION_FILE:                     2,
+		COMPRESSION_REPORT_FILE:            1,
+		COMPRESSION_REPORT_BOOK_LEVEL_FILE: 0,
+		DATA_CHECK_FILE:                    0,
+		BREAK_REPORT_FILE:                  3,
+		"proposals_A.csv":                  2,
+		"proposals_B.csv":                  1,
+	}
+
+	var buffer bytes.Buffer
+	if err := WriteRunBundle(&buffer, resp); err != nil {
+		t.Fatal(err)
+	}
+	zipReader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileNameToContent := make(map[string][]byte)
+	fileNames := make([]string, 0, len(zipReader.File))
+	for _, file := range zipReader.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fileNameToContent[file.Name] = content
+		fileNames = append(fileNames, file.Name)
+	}
+	if fileNames[len(fileNames)-1] != BUNDLE_MANIFEST_FILE {
+		t.Errorf("expected %s to be the last file, got %v", BUNDLE_MANIFEST_FILE, fileNames)
+	}
+
+	var manifest api.BundleManifest
+	if err = json.Unmarshal(fileNameToContent[BUNDLE_MANIFEST_FILE], &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.RequestID != resp.RequestID || len(manifest.Files) != len(expectedRows) {
+		t.Fatalf("expected a manifest of %d files for request %s, got %+v", len(expectedRows), resp.RequestID, manifest)
+	}
+	if manifest.Files[5].FileName != "proposals_A.csv" || manifest.Files[6].FileName != "proposals_B.csv" {
+		t.Errorf("expected the proposals to be sorted by party, got %+v", manifest.Files)
+	}
+	for _, file := range manifest.Files {
+		content, ok := fileNameToContent[file.FileName]
+		if !ok {
+			t.Errorf("expected %s in the bundle", file.FileName)
+			continue
+		}
+		hash := sha256.Sum256(content)
+		if file.SHA256 != hex.EncodeToString(hash[:]) {
+			t.Errorf("expected the hash of %s to be %x, got %s", file.FileName, hash, file.SHA256)
+		}
+		if file.Rows != expectedRows[file.FileName] {
+			t.Errorf("expected %s to have %d rows, got %d", file.FileName, expectedRows[file.FileName], file.Rows)
+		}
+	}
+}
+
+func TestWriteRunBundleWithInvalidFile(t *testing.T) {
+	resp := &api.CompressTradesResp{
+		RequestID:         "run",
+		CompressionReport: encodeTestFile("Party,Notional\nA,\"100\n"),
+	}
+	if err := WriteRunBundle(ioutil.Discard, resp); err == nil {
+		t.Errorf("expected an error for a report with an unterminated quote")
+	}
+}
+
+func TestStreamAttachment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/complete", func(c *gin.Context) {
+		streamAttachment(c, "run", "text/plain", "run.txt", "write", func(w io.Writer) error {
+			_, err := w.Write([]byte("complete"))
+			return err
+		})
+	})
+	router.GET("/before_writing", func(c *gin.Context) {
+		streamAttachment(c, "run", "text/plain", "run.txt", "write", func(w io.Writer) error {
+			return errors.New("failed")
+		})
+	})
+	router.GET("/after_writing", func(c *gin.Context) {
+		streamAttachment(c, "run", "text/plain", "run.txt", "write", func(w io.Writer) error {
+			if _, err := w.Write(bytes.Repeat([]byte("partial"), 1000)); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+			return errors.New("failed")
+		})
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/complete")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK || string(body) != "complete" {
+		t.Errorf("expected status 200 with the file, got %d %q %v", resp.StatusCode, body, err)
+	}
+	if resp.Header.Get("Content-Disposition") != "attachment; filename=\"run.txt\"" {
+		t.Errorf("expected the file name in Content-Disposition, got %q", resp.Header.Get("Content-Disposition"))
+	}
+
+	resp, err = http.Get(server.URL + "/before_writing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError || len(resp.Header.Get("Content-Disposition")) > 0 {
+		t.Errorf("expected status 500 without attachment, got %d %q", resp.StatusCode, resp.Header.Get("Content-Disposition"))
+	}
+
+	resp, err = http.Get(server.URL + "/after_writing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err == nil {
+		t.Errorf("expected the response to be cut off after an error in the middle of the file")
+	}
+}
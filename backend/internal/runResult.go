@@ -0,0 +1,363 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gocarina/gocsv"
+	"github.com/zytan787/code-to-connect-2021/api"
+	"github.com/zytan787/code-to-connect-2021/internal/toolkit"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const RESULT_FORMAT_CSV = "csv"
+const RESULT_FORMAT_JSON = "json"
+const RESULT_MIME_CSV = "text/csv"
+
+type RunResult struct {
+	RequestID                   string
+	ExcludedTrades              []*ExcludedTrade
+	CompressionResults          []*CompressionResult
+	BookLevelCompressionResults []*CompressionResultBookLevel
+	PartyToProposals            map[string][]*Proposal
+	DataCheckResults            []*DataCheckResult
+	DataCheckTotal              *DataCheckResult
+	BreakReport                 []*BreakCandidate
+	Statistics                  []api.Statistic
+}
+
+func (handler *MainHandler) GetRunResult(requestID string) *RunResult {
+	return &RunResult{
+		RequestID:                   requestID,
+		ExcludedTrades:              handler.GetExcludedTrades(),
+		CompressionResults:          handler.GetCompressionResults(),
+		BookLevelCompressionResults: handler.GetBookLevelCompressionResults(),
+		PartyToProposals:            handler.GetPartyToProposals(),
+		DataCheckResults:            handler.GetDataCheckResults(),
+		DataCheckTotal:              handler.GetDataCheckTotal(),
+		BreakReport:                 handler.GetBreakReport(),
+		Statistics:                  handler.GetStatistics(),
+	}
+}
+
+func ParseRunResult(resp *api.CompressTradesResp) (*RunResult, error) {
+	result := &RunResult{
+		RequestID:        resp.RequestID,
+		PartyToProposals: make(map[string][]*Proposal),
+		Statistics:       resp.Statistics,
+	}
+
+	if err := unmarshalBase64CSV(resp.Exclusion, &result.ExcludedTrades); err != nil {
+		return nil, fmt.Errorf("unable to parse exclusion due to: %s", err.Error())
+	}
+	if err := unmarshalBase64CSV(resp.CompressionReport, &result.CompressionResults); err != nil {
+		return nil, fmt.Errorf("unable to parse compression report due to: %s", err.Error())
+	}
+	if err := unmarshalBase64CSV(resp.CompressionReportBookLevel, &result.BookLevelCompressionResults); err != nil {
+		return nil, fmt.Errorf("unable to parse book level compression report due to: %s", err.Error())
+	}
+	// runs stored before the compression reports had an AdjustedMaturityDate column hold the adjusted date in MaturityDate
+	for _, compressionResult := range result.CompressionResults {
+		if len(compressionResult.AdjustedMaturityDate) == 0 {
+			compressionResult.AdjustedMaturityDate = compressionResult.MaturityDate
+		}
+	}
+	for _, compressionResult := range result.BookLevelCompressionResults {
+		if len(compressionResult.AdjustedMaturityDate) == 0 {
+			compressionResult.AdjustedMaturityDate = compressionResult.MaturityDate
+		}
+	}
+	for _, proposal := range resp.Proposals {
+		proposalBytes, err := base64.StdEncoding.DecodeString(proposal.Proposal)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse proposals of party %s due to: %s", proposal.Party, err.Error())
+		}
+		proposals, err := UnmarshalProposalsCSV(proposalBytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse proposals of party %s due to: %s", proposal.Party, err.Error())
+		}
+		result.PartyToProposals[proposal.Party] = proposals
+	}
+	if err := unmarshalBase64CSV(resp.DataCheck, &result.DataCheckResults); err != nil {
+		return nil, fmt.Errorf("unable to parse data check due to: %s", err.Error())
+	}
+	if err := unmarshalBase64CSV(resp.BreakReport, &result.BreakReport); err != nil {
+		return nil, fmt.Errorf("unable to parse break report due to: %s", err.Error())
+	}
+	if len(result.DataCheckResults) > 0 {
+		result.DataCheckTotal = result.DataCheckResults[len(result.DataCheckResults)-1]
+		result.DataCheckResults = result.DataCheckResults[:len(result.DataCheckResults)-1]
+	}
+
+	return result, nil
+}
+
+func unmarshalBase64CSV(content string, out interface{}) error {
+	contentBytes, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return err
+	}
+	if len(contentBytes) == 0 {
+		return nil
+	}
+	return gocsv.UnmarshalBytes(contentBytes, out)
+}
+
+func (result *RunResult) GetParties() []string {
+	parties := make([]string, 0, len(result.PartyToProposals))
+	for party := range result.PartyToProposals {
+		parties = append(parties, party)
+	}
+	sort.Strings(parties)
+	return parties
+}
+
+// ToAPIResult converts the report rows of the run into typed rows, it fails on a row number, notional or rate
+// that is not a number instead of writing 0
+func (result *RunResult) ToAPIResult() (*api.CompressTradesResult, error) {
+	apiResult := &api.CompressTradesResult{
+		RequestID:                  result.RequestID,
+		Exclusion:                  make([]api.ExcludedTrade, len(result.ExcludedTrades)),
+		CompressionReport:          make([]api.CompressionResult, len(result.CompressionResults)),
+		CompressionReportBookLevel: make([]api.CompressionResultBookLevel, len(result.BookLevelCompressionResults)),
+		Proposals:                  make([]api.PartyProposals, 0, len(result.PartyToProposals)),
+		DataCheck:                  make([]api.DataCheckResult, 0, len(result.DataCheckResults)+1),
+		BreakReport:                make([]api.BreakCandidate, len(result.BreakReport)),
+		Statistics:                 result.Statistics,
+	}
+
+	var err error
+	for i, excludedTrade := range result.ExcludedTrades {
+		apiResult.Exclusion[i] = api.ExcludedTrade{
+			Party:                excludedTrade.Party,
+			Book:                 excludedTrade.Book,
+			TradeID:              excludedTrade.TradeID,
+			PayOrReceive:         excludedTrade.PayOrReceive,
+			Currency:             excludedTrade.Currency,
+			MaturityDate:         excludedTrade.MaturityDate,
+			AdjustedMaturityDate: excludedTrade.AdjustedMaturityDate,
+			Cpty:                 excludedTrade.Cpty,
+			CCPTradeID:           excludedTrade.CCPTradeID,
+			Error:                excludedTrade.Error,
+			Warning:              excludedTrade.Warning,
+			FileName:             excludedTrade.FileName,
+		}
+		if apiResult.Exclusion[i].RowNumber, err = parseRowNumber(excludedTrade.RowNumber); err != nil {
+			return nil, fmt.Errorf("unable to parse exclusion due to: %s", err.Error())
+		}
+		if notional, err := toolkit.ParseDecimal(excludedTrade.Notional); err == nil && notional.Sign() >= 0 {
+			apiResult.Exclusion[i].Notional = json.Number(notional.String())
+		} else {
+			apiResult.Exclusion[i].RawNotional = excludedTrade.Notional
+		}
+	}
+
+	for i, compressionResult := range result.CompressionResults {
+		compressionRate, err := parseCompressionRate(compressionResult.OriginalNotional, compressionResult.Notional)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse compression report due to: %s", err.Error())
+		}
+		apiResult.CompressionReport[i] = api.CompressionResult{
+			Party:                compressionResult.Party,
+			Currency:             compressionResult.Currency,
+			MaturityDate:         compressionResult.MaturityDate,
+			AdjustedMaturityDate: compressionResult.AdjustedMaturityDate,
+			PayOrReceive:         compressionResult.PayOrReceive,
+			CompressionType:      string(compressionResult.CompressionType),
+			OriginalNotional:     json.Number(compressionResult.OriginalNotional),
+			Notional:             json.Number(compressionResult.Notional),
+			CompressionRate:      compressionRate,
+			Warning:              compressionResult.Warning,
+		}
+	}
+
+	for i, compressionResult := range result.BookLevelCompressionResults {
+		compressionRate, err := parseCompressionRate(compressionResult.OriginalNotional, compressionResult.Notional)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse book level compression report due to: %s", err.Error())
+		}
+		apiResult.CompressionReportBookLevel[i] = api.CompressionResultBookLevel{
+			Party:                compressionResult.Party,
+			Book:                 compressionResult.Book,
+			Currency:             compressionResult.Currency,
+			MaturityDate:         compressionResult.MaturityDate,
+			AdjustedMaturityDate: compressionResult.AdjustedMaturityDate,
+			PayOrReceive:         compressionResult.PayOrReceive,
+			CompressionType:      string(compressionResult.CompressionType),
+			OriginalNotional:     json.Number(compressionResult.OriginalNotional),
+			Notional:             json.Number(compressionResult.Notional),
+			CompressionRate:      compressionRate,
+			Warning:              compressionResult.Warning,
+		}
+	}
+
+	for _, party := range result.GetParties() {
+		proposals := result.PartyToProposals[party]
+		partyProposals := api.PartyProposals{
+			Party:     party,
+			Proposals: make([]api.ProposalRow, len(proposals)),
+		}
+		for i, proposal := range proposals {
+			partyProposals.Proposals[i] = api.ProposalRow{
+				Party:                proposal.Party,
+				Book:                 proposal.Book,
+				TradeID:              proposal.TradeID,
+				PayOrReceive:         proposal.PayOrReceive,
+				Currency:             proposal.Currency,
+				MaturityDate:         proposal.MaturityDate,
+				AdjustedMaturityDate: proposal.AdjustedMaturityDate,
+				Cpty:                 proposal.Cpty,
+				CCPTradeID:           proposal.CCPTradeID,
+				Notional:             json.Number(proposal.Notional.String()),
+				Action:               string(proposal.Action),
+				Warning:              proposal.Warning,
+				Attributes:           proposal.Attributes,
+			}
+		}
+		apiResult.Proposals = append(apiResult.Proposals, partyProposals)
+	}
+
+	dataCheckResults := result.DataCheckResults
+	if result.DataCheckTotal != nil {
+		dataCheckResults = append(dataCheckResults[:len(dataCheckResults):len(dataCheckResults)], result.DataCheckTotal)
+	}
+	for _, dataCheckResult := range dataCheckResults {
+		apiResult.DataCheck = append(apiResult.DataCheck, api.DataCheckResult{
+			Party:            dataCheckResult.Party,
+			TotalIn:          json.Number(dataCheckResult.TotalIn.String()),
+			TotalOut:         json.Number(dataCheckResult.TotalOut.String()),
+			NetOut:           json.Number(dataCheckResult.NetOut.String()),
+			OriginalNotional: json.Number(dataCheckResult.OriginalNotional.String()),
+			Notional:         json.Number(dataCheckResult.Notional.String()),
+			Reduced:          dataCheckResult.Reduced,
+		})
+	}
+
+	for i, breakCandidate := range result.BreakReport {
+		similarity, err := parseSimilarity(breakCandidate.Similarity)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse break report due to: %s", err.Error())
+		}
+		apiResult.BreakReport[i] = api.BreakCandidate{
+			Party:               breakCandidate.Party,
+			Book:                breakCandidate.Book,
+			TradeID:             breakCandidate.TradeID,
+			CCPTradeID:          breakCandidate.CCPTradeID,
+			FileName:            breakCandidate.FileName,
+			Similarity:          similarity,
+			CandidateParty:      breakCandidate.CandidateParty,
+			CandidateBook:       breakCandidate.CandidateBook,
+			CandidateTradeID:    breakCandidate.CandidateTradeID,
+			CandidateCCPTradeID: breakCandidate.CandidateCCPTradeID,
+			CandidateFileName:   breakCandidate.CandidateFileName,
+			Differences:         breakCandidate.Differences,
+		}
+		if apiResult.BreakReport[i].RowNumber, err = parseRowNumber(breakCandidate.RowNumber); err != nil {
+			return nil, fmt.Errorf("unable to parse break report due to: %s", err.Error())
+		}
+		if apiResult.BreakReport[i].Rank, err = parseRowNumber(breakCandidate.Rank); err != nil {
+			return nil, fmt.Errorf("unable to parse break report due to: %s", err.Error())
+		}
+		if apiResult.BreakReport[i].CandidateRowNumber, err = parseRowNumber(breakCandidate.CandidateRowNumber); err != nil {
+			return nil, fmt.Errorf("unable to parse break report due to: %s", err.Error())
+		}
+	}
+
+	return apiResult, nil
+}
+
+// parseRowNumber reads an empty row number, like the one of a trade read from FpML, as 0
+func parseRowNumber(rowNumber string) (int, error) {
+	if len(rowNumber) == 0 {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(rowNumber)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a whole number", rowNumber)
+	}
+	return value, nil
+}
+
+func parseCompressionRate(originalNotional string, notional string) (float64, error) {
+	original, err := toolkit.ParseDecimal(originalNotional)
+	if err != nil {
+		return 0, fmt.Errorf("Original_Notional %s", err.Error())
+	}
+	remaining, err := toolkit.ParseDecimal(notional)
+	if err != nil {
+		return 0, fmt.Errorf("Notional %s", err.Error())
+	}
+	return calculateCompressionRate(original, remaining), nil
+}
+
+// GetResultFormat takes the format from the format query parameter, or else from the Accept header. The CSV format
+// is offered first so that a client accepting anything, or sending no Accept header, keeps getting the CSVs.
+func GetResultFormat(c *gin.Context) (string, error) {
+	format, ok := c.GetQuery("format")
+	if !ok {
+		if c.NegotiateFormat(RESULT_MIME_CSV, gin.MIMEJSON) == gin.MIMEJSON {
+			return RESULT_FORMAT_JSON, nil
+		}
+		return RESULT_FORMAT_CSV, nil
+	}
+
+	format = strings.ToLower(format)
+	if format != RESULT_FORMAT_CSV && format != RESULT_FORMAT_JSON {
+		return "", fmt.Errorf("format %s is neither '%s' or '%s'", format, RESULT_FORMAT_CSV, RESULT_FORMAT_JSON)
+	}
+	return format, nil
+}
+
+func WriteCompressTradesResp(c *gin.Context, statusCode int, resp *api.CompressTradesResp) {
+	WriteCompressTradesResult(c, statusCode, resp, nil)
+}
+
+// WriteCompressTradesResult writes the typed rows of result when the JSON format is requested, a nil result is
+// parsed from the CSVs of resp, which is only needed for a run loaded from the run store
+func WriteCompressTradesResult(c *gin.Context, statusCode int, resp *api.CompressTradesResp, result *RunResult) {
+	format, err := GetResultFormat(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, api.CompressTradesResp{
+			RequestID: resp.RequestID,
+			Error:     err.Error(),
+		})
+		return
+	}
+
+	if format == RESULT_FORMAT_CSV {
+		c.JSON(statusCode, resp)
+		return
+	}
+
+	if len(resp.Error) > 0 {
+		c.JSON(statusCode, api.CompressTradesResult{
+			RequestID: resp.RequestID,
+			Error:     resp.Error,
+		})
+		return
+	}
+
+	if result == nil {
+		result, err = ParseRunResult(resp)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, api.CompressTradesResult{
+				RequestID: resp.RequestID,
+				Error:     fmt.Sprintf("Error in ParseRunResult due to: %s", err.Error()),
+			})
+			return
+		}
+	}
+	apiResult, err := result.ToAPIResult()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, api.CompressTradesResult{
+			RequestID: resp.RequestID,
+			Error:     fmt.Sprintf("Error in ToAPIResult due to: %s", err.Error()),
+		})
+		return
+	}
+	c.JSON(statusCode, apiResult)
+}
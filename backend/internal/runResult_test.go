@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/zytan787/code-to-connect-2021/api"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestGetResultFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+		format string
+		valid  bool
+	}{
+		{"no accept header", "", "", RESULT_FORMAT_CSV, true},
+		{"any type", "", "*/*", RESULT_FORMAT_CSV, true},
+		{"json", "", "application/json", RESULT_FORMAT_JSON, true},
+		{"json with parameters", "", "application/json; charset=utf-8", RESULT_FORMAT_JSON, true},
+		{"json before any type", "", "application/json, */*;q=0.8", RESULT_FORMAT_JSON, true},
+		{"csv", "", "text/csv", RESULT_FORMAT_CSV, true},
+		{"unknown type", "", "text/html", RESULT_FORMAT_CSV, true},
+		{"query over accept header", "?format=csv", "application/json", RESULT_FORMAT_CSV, true},
+		{"query without accept header", "?format=JSON", "", RESULT_FORMAT_JSON, true},
+		{"unknown query", "?format=xml", "application/json", "", false},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/runs/run"+test.query, nil)
+			if len(test.accept) > 0 {
+				c.Request.Header.Set("Accept", test.accept)
+			}
+
+			format, err := GetResultFormat(c)
+			if test.valid && err != nil {
+				t.Fatalf("expected format %s, got %s", test.format, err.Error())
+			}
+			if !test.valid && err == nil {
+				t.Fatalf("expected an error, got format %s", format)
+			}
+			if format != test.format {
+				t.Errorf("expected format %s, got %s", test.format, format)
+			}
+		})
+	}
+}
+
+func TestRunResultMatchesStoredCSVs(t *testing.T) {
+	handler := NewMainHandler()
+	resp := &api.CompressTradesResp{RequestID: "run"}
+	inputFiles := []api.File{{FileName: "input.csv", FileContent: encodeTestFile(TEST_INPUT_FILE)}}
+	if statusCode := handler.runCompression(inputFiles, resp, logrus.WithFields(logrus.Fields{})); statusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d %s", statusCode, resp.Error)
+	}
+	if handler.runResult == nil {
+		t.Fatal("expected the run result to be kept by the handler")
+	}
+
+	result, err := handler.runResult.ToAPIResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	storedResult, err := ParseRunResult(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedResult, err := storedResult.ToAPIResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.CompressionReport) == 0 || len(result.Proposals) == 0 {
+		t.Fatalf("expected compression results and proposals, got %+v", result)
+	}
+	if !reflect.DeepEqual(result, expectedResult) {
+		t.Errorf("expected the rows of the run to match the rows of its CSVs, got %+v and %+v", result, expectedResult)
+	}
+}
@@ -0,0 +1,411 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/zytan787/code-to-connect-2021/api"
+	"github.com/zytan787/code-to-connect-2021/internal/toolkit"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const RUN_METADATA_FILE = "run.json"
+const RUN_INPUT_DIR = "input"
+const RUN_TMP_PREFIX = ".tmp-"
+const EXCLUSION_FILE = "exclusion.csv"
+const BREAK_REPORT_FILE = "break_report.csv"
+const COMPRESSION_REPORT_FILE = "compression_report.csv"
+const COMPRESSION_REPORT_BOOK_LEVEL_FILE = "compression_report_book_level.csv"
+const DATA_CHECK_FILE = "data_check.csv"
+const PROPOSALS_FILE_FORMAT = "proposals_%s.csv"
+
+var ErrRequestIDConflict = errors.New("request_id was already used with different input files or options")
+
+type RunStore struct {
+	dir             string
+	mutex           sync.Mutex
+	requestIDToLock map[string]*requestIDLock
+}
+
+type requestIDLock struct {
+	mutex   sync.Mutex
+	waiters int
+}
+
+type RunWriter struct {
+	store       *RunStore
+	requestID   string
+	tmpDir      string
+	inputFiles  []string
+	inputHasher *InputHasher
+}
+
+type InputHasher struct {
+	hash hash.Hash
+}
+
+type inputFileWriter struct {
+	io.Writer
+	file        *os.File
+	fileName    string
+	sheetName   string
+	contentHash hash.Hash
+	inputHasher *InputHasher
+}
+
+func NewRunStore(dir string) (*RunStore, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create run store directory %s due to: %s", dir, err.Error())
+	}
+	return &RunStore{
+		dir:             dir,
+		requestIDToLock: make(map[string]*requestIDLock),
+	}, nil
+}
+
+func (store *RunStore) LockRequestID(requestID string) func() {
+	store.mutex.Lock()
+	lock, ok := store.requestIDToLock[requestID]
+	if !ok {
+		lock = &requestIDLock{}
+		store.requestIDToLock[requestID] = lock
+	}
+	lock.waiters++
+	store.mutex.Unlock()
+
+	lock.mutex.Lock()
+
+	return func() {
+		lock.mutex.Unlock()
+
+		store.mutex.Lock()
+		lock.waiters--
+		if lock.waiters == 0 {
+			delete(store.requestIDToLock, requestID)
+		}
+		store.mutex.Unlock()
+	}
+}
+
+func (store *RunStore) FindRun(requestID string, inputHash string) (*api.CompressTradesResp, error) {
+	runSummary, err := store.LoadRunSummary(requestID)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if runSummary.InputHash != inputHash {
+		return nil, ErrRequestIDConflict
+	}
+	return store.LoadRun(requestID)
+}
+
+// NewInputHasher starts the hash of a run with the settings it was run with, the input files are added after them
+func NewInputHasher(settings []byte) *InputHasher {
+	inputHasher := &InputHasher{hash: sha256.New()}
+	fmt.Fprintf(inputHasher.hash, "settings %x\n", sha256.Sum256(settings))
+	return inputHasher
+}
+
+func (inputHasher *InputHasher) AddFile(fileName string, sheetName string, in io.Reader) error {
+	contentHash := sha256.New()
+	_, err := io.Copy(contentHash, in)
+	if err != nil {
+		return err
+	}
+	inputHasher.addFileHash(fileName, sheetName, contentHash.Sum(nil))
+	return nil
+}
+
+func (inputHasher *InputHasher) addFileHash(fileName string, sheetName string, contentHash []byte) {
+	if len(sheetName) > 0 {
+		fmt.Fprintf(inputHasher.hash, "%s\nsheet %s\n%x\n", filepath.Base(fileName), sheetName, contentHash)
+		return
+	}
+	fmt.Fprintf(inputHasher.hash, "%s\n%x\n", filepath.Base(fileName), contentHash)
+}
+
+func (inputHasher *InputHasher) Sum() string {
+	return hex.EncodeToString(inputHasher.hash.Sum(nil))
+}
+
+// getRunDirName names the directory of a run after the hash of its request_id, so that any request_id can be stored
+// whatever characters it contains, the request_id itself is kept in the run metadata
+func getRunDirName(requestID string) string {
+	hash := sha256.Sum256([]byte(requestID))
+	return hex.EncodeToString(hash[:])
+}
+
+func (store *RunStore) NewRunWriter(requestID string, inputHasher *InputHasher) (*RunWriter, error) {
+	tmpDir := filepath.Join(store.dir, fmt.Sprintf("%s%s-%s", RUN_TMP_PREFIX, getRunDirName(requestID), toolkit.UniqueID()))
+	err := os.MkdirAll(filepath.Join(tmpDir, RUN_INPUT_DIR), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RunWriter{
+		store:       store,
+		requestID:   requestID,
+		tmpDir:      tmpDir,
+		inputHasher: inputHasher,
+	}, nil
+}
+
+func (writer *RunWriter) CreateInputFile(fileName string, sheetName string) (io.WriteCloser, error) {
+	storedFileName := fmt.Sprintf("%02d_%s", len(writer.inputFiles)+1, filepath.Base(fileName))
+	writer.inputFiles = append(writer.inputFiles, storedFileName)
+
+	file, err := os.Create(filepath.Join(writer.tmpDir, RUN_INPUT_DIR, storedFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	contentHash := sha256.New()
+	return &inputFileWriter{
+		Writer:      io.MultiWriter(file, contentHash),
+		file:        file,
+		fileName:    fileName,
+		sheetName:   sheetName,
+		contentHash: contentHash,
+		inputHasher: writer.inputHasher,
+	}, nil
+}
+
+func (writer *inputFileWriter) Close() error {
+	writer.inputHasher.addFileHash(writer.fileName, writer.sheetName, writer.contentHash.Sum(nil))
+	return writer.file.Close()
+}
+
+func (writer *RunWriter) SaveInputFile(fileName string, sheetName string, in io.Reader) error {
+	file, err := writer.CreateInputFile(fileName, sheetName)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, in)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (writer *RunWriter) Commit(resp *api.CompressTradesResp) error {
+	files := map[string]string{
+		EXCLUSION_FILE:                     resp.Exclusion,
+		COMPRESSION_REPORT_FILE:            resp.CompressionReport,
+		COMPRESSION_REPORT_BOOK_LEVEL_FILE: resp.CompressionReportBookLevel,
+		DATA_CHECK_FILE:                    resp.DataCheck,
+		BREAK_REPORT_FILE:                  resp.BreakReport,
+	}
+	for _, proposal := range resp.Proposals {
+		files[GetProposalsFileName(proposal.Party)] = proposal.Proposal
+	}
+
+	for fileName, content := range files {
+		contentBytes, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(filepath.Join(writer.tmpDir, fileName), contentBytes, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	metadata, err := json.MarshalIndent(api.RunSummary{
+		RequestID:  writer.requestID,
+		CreatedAt:  time.Now().Format(time.RFC3339),
+		InputFiles: writer.inputFiles,
+		InputHash:  writer.inputHasher.Sum(),
+		Statistics: resp.Statistics,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(writer.tmpDir, RUN_METADATA_FILE), metadata, 0644)
+	if err != nil {
+		return err
+	}
+
+	runDir := filepath.Join(writer.store.dir, getRunDirName(writer.requestID))
+	err = os.RemoveAll(runDir)
+	if err != nil {
+		return err
+	}
+	return os.Rename(writer.tmpDir, runDir)
+}
+
+func GetProposalsFileName(party string) string {
+	return fmt.Sprintf(PROPOSALS_FILE_FORMAT, url.PathEscape(party))
+}
+
+func (writer *RunWriter) Discard() {
+	os.RemoveAll(writer.tmpDir)
+}
+
+func (store *RunStore) LoadRunSummary(requestID string) (*api.RunSummary, error) {
+	runSummary, err := store.loadRunSummaryFromDir(getRunDirName(requestID))
+	if err != nil {
+		return nil, err
+	}
+	if runSummary.RequestID != requestID {
+		return nil, fmt.Errorf("run directory %s holds request_id %s instead of %s", getRunDirName(requestID),
+			runSummary.RequestID, requestID)
+	}
+	return runSummary, nil
+}
+
+func (store *RunStore) loadRunSummaryFromDir(runDirName string) (*api.RunSummary, error) {
+	metadata, err := ioutil.ReadFile(filepath.Join(store.dir, runDirName, RUN_METADATA_FILE))
+	if err != nil {
+		return nil, err
+	}
+
+	var runSummary api.RunSummary
+	err = json.Unmarshal(metadata, &runSummary)
+	if err != nil {
+		return nil, err
+	}
+	return &runSummary, nil
+}
+
+func (store *RunStore) LoadRun(requestID string) (*api.CompressTradesResp, error) {
+	runSummary, err := store.LoadRunSummary(requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	runDir := filepath.Join(store.dir, getRunDirName(requestID))
+	readAsBase64 := func(fileName string) (string, error) {
+		content, err := ioutil.ReadFile(filepath.Join(runDir, fileName))
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(content), nil
+	}
+
+	resp := &api.CompressTradesResp{
+		RequestID:  runSummary.RequestID,
+		Statistics: runSummary.Statistics,
+	}
+	if resp.Exclusion, err = readAsBase64(EXCLUSION_FILE); err != nil {
+		return nil, err
+	}
+	if resp.CompressionReport, err = readAsBase64(COMPRESSION_REPORT_FILE); err != nil {
+		return nil, err
+	}
+	if resp.CompressionReportBookLevel, err = readAsBase64(COMPRESSION_REPORT_BOOK_LEVEL_FILE); err != nil {
+		return nil, err
+	}
+	if resp.DataCheck, err = readAsBase64(DATA_CHECK_FILE); err != nil {
+		return nil, err
+	}
+	// runs stored before the break report was added have no break report
+	if resp.BreakReport, err = readAsBase64(BREAK_REPORT_FILE); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	proposalFiles, err := filepath.Glob(filepath.Join(runDir, fmt.Sprintf(PROPOSALS_FILE_FORMAT, "*")))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(proposalFiles)
+
+	resp.Proposals = make([]api.Proposal, 0, len(proposalFiles))
+	var party, proposal string
+	for _, proposalFile := range proposalFiles {
+		party = strings.TrimSuffix(strings.TrimPrefix(filepath.Base(proposalFile), "proposals_"), ".csv")
+		if party, err = url.PathUnescape(party); err != nil {
+			return nil, err
+		}
+		if proposal, err = readAsBase64(filepath.Base(proposalFile)); err != nil {
+			return nil, err
+		}
+		resp.Proposals = append(resp.Proposals, api.Proposal{
+			Party:    party,
+			Proposal: proposal,
+		})
+	}
+
+	return resp, nil
+}
+
+func (store *RunStore) ListRuns() ([]api.RunSummary, error) {
+	entries, err := ioutil.ReadDir(store.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]api.RunSummary, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), RUN_TMP_PREFIX) {
+			continue
+		}
+		runSummary, err := store.loadRunSummaryFromDir(entry.Name())
+		if err != nil {
+			continue
+		}
+		runSummary.Statistics = nil
+		result = append(result, *runSummary)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt > result[j].CreatedAt
+	})
+
+	return result, nil
+}
+
+func (store *RunStore) GetRun(c *gin.Context) {
+	resp, ok := store.loadRequestedRun(c)
+	if !ok {
+		return
+	}
+
+	WriteCompressTradesResp(c, http.StatusOK, resp)
+}
+
+func (store *RunStore) loadRequestedRun(c *gin.Context) (*api.CompressTradesResp, bool) {
+	resp, err := store.LoadRun(c.Param("id"))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if os.IsNotExist(err) {
+			statusCode = http.StatusNotFound
+			err = fmt.Errorf("run not found")
+		}
+		WriteCompressTradesResp(c, statusCode, &api.CompressTradesResp{
+			RequestID: c.Param("id"),
+			Error:     err.Error(),
+		})
+		return nil, false
+	}
+	return resp, true
+}
+
+func (store *RunStore) GetRuns(c *gin.Context) {
+	runs, err := store.ListRuns()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
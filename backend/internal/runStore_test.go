@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"github.com/zytan787/code-to-connect-2021/api"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunStoreRequestIDs(t *testing.T) {
+	requestIDs := []string{
+		"run-1",
+		"run with spaces",
+		"desk/run:2021-06-30",
+		"../outside",
+		".tmp-run",
+		strings.Repeat("x", 300),
+	}
+
+	runStore, err := NewRunStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, requestID := range requestIDs {
+		runWriter, err := runStore.NewRunWriter(requestID, NewInputHasher(nil))
+		if err != nil {
+			t.Fatalf("expected request_id %q to be stored, got %s", requestID, err.Error())
+		}
+		if err = runWriter.SaveInputFile("input.csv", "", strings.NewReader(TEST_INPUT_FILE)); err != nil {
+			t.Fatal(err)
+		}
+		if err = runWriter.Commit(&api.CompressTradesResp{RequestID: requestID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, requestID := range requestIDs {
+		resp, err := runStore.LoadRun(requestID)
+		if err != nil {
+			t.Fatalf("expected request_id %q to be loaded, got %s", requestID, err.Error())
+		}
+		if resp.RequestID != requestID {
+			t.Errorf("expected request_id %q, got %q", requestID, resp.RequestID)
+		}
+	}
+
+	runs, err := runStore.ListRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != len(requestIDs) {
+		t.Errorf("expected %d runs, got %d", len(requestIDs), len(runs))
+	}
+
+	if _, err = runStore.LoadRun("unknown"); err == nil {
+		t.Errorf("expected an error loading an unknown request_id")
+	}
+}
+
+func TestRunStoreKeepsRuns(t *testing.T) {
+	dir := t.TempDir()
+	runStore, err := NewRunStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputHasher := NewInputHasher(nil)
+	runWriter, err := runStore.NewRunWriter("run", inputHasher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = runWriter.SaveInputFile("input.csv", "", strings.NewReader(TEST_INPUT_FILE)); err != nil {
+		t.Fatal(err)
+	}
+	resp := &api.CompressTradesResp{
+		RequestID:                  "run",
+		Exclusion:                  encodeTestFile("exclusion"),
+		CompressionReport:          encodeTestFile("compression report"),
+		CompressionReportBookLevel: encodeTestFile("compression report book level"),
+		DataCheck:                  encodeTestFile("data check"),
+		BreakReport:                encodeTestFile("break report"),
+		Proposals:                  []api.Proposal{{Party: "A", Proposal: encodeTestFile("proposals of A")}},
+	}
+	if err = runWriter.Commit(resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// a run that fails before being committed leaves nothing behind
+	discardedWriter, err := runStore.NewRunWriter("discarded", NewInputHasher(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	discardedWriter.Discard()
+
+	// a restarted server reads the runs back from the same directory
+	runStore, err = NewRunStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storedResp, err := runStore.FindRun("run", inputHasher.Sum())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storedResp == nil || storedResp.Exclusion != resp.Exclusion || storedResp.CompressionReport != resp.CompressionReport ||
+		storedResp.CompressionReportBookLevel != resp.CompressionReportBookLevel || storedResp.DataCheck != resp.DataCheck ||
+		storedResp.BreakReport != resp.BreakReport || len(storedResp.Proposals) != 1 ||
+		storedResp.Proposals[0] != resp.Proposals[0] {
+		t.Errorf("expected the stored run %+v, got %+v", resp, storedResp)
+	}
+
+	runSummary, err := runStore.LoadRunSummary("run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runSummary.InputFiles) != 1 {
+		t.Fatalf("expected 1 stored input file, got %v", runSummary.InputFiles)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, getRunDirName("run"), RUN_INPUT_DIR, runSummary.InputFiles[0]))
+	if err != nil || string(content) != TEST_INPUT_FILE {
+		t.Errorf("expected the input file to be stored, got %q %v", content, err)
+	}
+
+	runs, err := runStore.ListRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].RequestID != "run" {
+		t.Errorf("expected only the committed run to be listed, got %+v", runs)
+	}
+
+	if _, err = runStore.FindRun("run", NewInputHasher([]byte("other settings")).Sum()); err != ErrRequestIDConflict {
+		t.Errorf("expected %v for another input hash, got %v", ErrRequestIDConflict, err)
+	}
+	if storedResp, err = runStore.FindRun("discarded", inputHasher.Sum()); storedResp != nil || err != nil {
+		t.Errorf("expected no run for a discarded request_id, got %+v %v", storedResp, err)
+	}
+}
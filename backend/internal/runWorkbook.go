@@ -0,0 +1,411 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+	"github.com/zytan787/code-to-connect-2021/api"
+	"github.com/zytan787/code-to-connect-2021/internal/toolkit"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const WORKBOOK_EXCLUSION_SHEET = "Exclusions"
+const WORKBOOK_BREAK_REPORT_SHEET = "Breaks"
+const WORKBOOK_COMPRESSION_REPORT_SHEET = "Compression Report"
+const WORKBOOK_COMPRESSION_REPORT_BOOK_LEVEL_SHEET = "Book Level"
+const WORKBOOK_DATA_CHECK_SHEET = "Data Check"
+const WORKBOOK_PROPOSALS_SHEET_PREFIX = "Proposals "
+const WORKBOOK_MAX_SHEET_NAME_LENGTH = 31
+
+// cell styles, a notional is shown with thousands separators and with 2 decimal places when it has a fraction,
+// a compression rate is stored as a fraction and displayed as a percentage
+const WORKBOOK_NOTIONAL_FORMAT = 3
+const WORKBOOK_DECIMAL_NOTIONAL_FORMAT = 4
+const WORKBOOK_PERCENTAGE_FORMAT = 10
+
+var invalidSheetNameChars = strings.NewReplacer(":", "_", "\\", "_", "/", "_", "?", "_", "*", "_", "[", "_", "]", "_")
+
+type workbookWriter struct {
+	file                 *excelize.File
+	headerStyle          int
+	notionalStyle        int
+	decimalNotionalStyle int
+	rateStyle            int
+	sheetNames           map[string]bool
+}
+
+func WriteRunWorkbook(w io.Writer, result *RunResult) error {
+	file, err := newRunWorkbook(result)
+	if err != nil {
+		return err
+	}
+	return file.Write(w)
+}
+
+// newRunWorkbook fills a workbook with every sheet of the run, everything that can fail before the file is written
+func newRunWorkbook(result *RunResult) (*excelize.File, error) {
+	writer, err := newWorkbookWriter()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writer.writeExclusionSheet(result.ExcludedTrades); err != nil {
+		return nil, fmt.Errorf("unable to write sheet %s due to: %s", WORKBOOK_EXCLUSION_SHEET, err.Error())
+	}
+	if err := writer.writeCompressionReportSheet(result.CompressionResults); err != nil {
+		return nil, fmt.Errorf("unable to write sheet %s due to: %s", WORKBOOK_COMPRESSION_REPORT_SHEET, err.Error())
+	}
+	if err := writer.writeBookLevelSheet(result.BookLevelCompressionResults); err != nil {
+		return nil, fmt.Errorf("unable to write sheet %s due to: %s", WORKBOOK_COMPRESSION_REPORT_BOOK_LEVEL_SHEET, err.Error())
+	}
+	if err := writer.writeDataCheckSheet(result.DataCheckResults, result.DataCheckTotal); err != nil {
+		return nil, fmt.Errorf("unable to write sheet %s due to: %s", WORKBOOK_DATA_CHECK_SHEET, err.Error())
+	}
+	if err := writer.writeBreakReportSheet(result.BreakReport); err != nil {
+		return nil, fmt.Errorf("unable to write sheet %s due to: %s", WORKBOOK_BREAK_REPORT_SHEET, err.Error())
+	}
+	for _, party := range result.GetParties() {
+		if err := writer.writeProposalsSheet(party, result.PartyToProposals[party]); err != nil {
+			return nil, fmt.Errorf("unable to write proposals of party %s due to: %s", party, err.Error())
+		}
+	}
+
+	writer.file.SetActiveSheet(0)
+	return writer.file, nil
+}
+
+func newWorkbookWriter() (*workbookWriter, error) {
+	writer := &workbookWriter{
+		file:       excelize.NewFile(),
+		sheetNames: make(map[string]bool),
+	}
+
+	var err error
+	if writer.headerStyle, err = writer.file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}}); err != nil {
+		return nil, err
+	}
+	if writer.notionalStyle, err = writer.file.NewStyle(&excelize.Style{NumFmt: WORKBOOK_NOTIONAL_FORMAT}); err != nil {
+		return nil, err
+	}
+	if writer.decimalNotionalStyle, err = writer.file.NewStyle(&excelize.Style{NumFmt: WORKBOOK_DECIMAL_NOTIONAL_FORMAT}); err != nil {
+		return nil, err
+	}
+	if writer.rateStyle, err = writer.file.NewStyle(&excelize.Style{NumFmt: WORKBOOK_PERCENTAGE_FORMAT}); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+func (writer *workbookWriter) writeExclusionSheet(excludedTrades []*ExcludedTrade) error {
+	headers := []string{"Party", "Book", "TradeID", "PAY/RECEIVE", "Currency", "MaturityDate", "AdjustedMaturityDate", "Cpty", "CCPTradeID", "Notional", "Error", "Warning", "FileName", "RowNumber"}
+	rows := make([][]interface{}, len(excludedTrades))
+	for i, excludedTrade := range excludedTrades {
+		rows[i] = []interface{}{
+			excludedTrade.Party,
+			excludedTrade.Book,
+			excludedTrade.TradeID,
+			excludedTrade.PayOrReceive,
+			excludedTrade.Currency,
+			excludedTrade.MaturityDate,
+			excludedTrade.AdjustedMaturityDate,
+			excludedTrade.Cpty,
+			excludedTrade.CCPTradeID,
+			numericCellValue(excludedTrade.Notional),
+			excludedTrade.Error,
+			excludedTrade.Warning,
+			excludedTrade.FileName,
+			numericCellValue(excludedTrade.RowNumber),
+		}
+	}
+
+	return writer.writeSheet(WORKBOOK_EXCLUSION_SHEET, headers, rows, map[int]int{9: writer.notionalStyle})
+}
+
+func (writer *workbookWriter) writeCompressionReportSheet(compressionResults []*CompressionResult) error {
+	headers := []string{"Party", "Currency", "MaturityDate", "AdjustedMaturityDate", "PAY/RECEIVE", "CompressionType", "Original_Notional", "Notional", "CompressionRate", "Warning"}
+	rows := make([][]interface{}, len(compressionResults))
+	for i, compressionResult := range compressionResults {
+		compressionRate, err := parseCompressionRate(compressionResult.OriginalNotional, compressionResult.Notional)
+		if err != nil {
+			return fmt.Errorf("unable to parse compression report due to: %s", err.Error())
+		}
+		rows[i] = []interface{}{
+			compressionResult.Party,
+			compressionResult.Currency,
+			compressionResult.MaturityDate,
+			compressionResult.AdjustedMaturityDate,
+			compressionResult.PayOrReceive,
+			string(compressionResult.CompressionType),
+			numericCellValue(compressionResult.OriginalNotional),
+			numericCellValue(compressionResult.Notional),
+			compressionRate / 100,
+			compressionResult.Warning,
+		}
+	}
+
+	return writer.writeSheet(WORKBOOK_COMPRESSION_REPORT_SHEET, headers, rows, map[int]int{
+		6: writer.notionalStyle,
+		7: writer.notionalStyle,
+		8: writer.rateStyle,
+	})
+}
+
+func (writer *workbookWriter) writeBookLevelSheet(compressionResults []*CompressionResultBookLevel) error {
+	headers := []string{"Party", "Book", "Currency", "MaturityDate", "AdjustedMaturityDate", "PAY/RECEIVE", "CompressionType", "Original_Notional", "Notional", "CompressionRate", "Warning"}
+	rows := make([][]interface{}, len(compressionResults))
+	for i, compressionResult := range compressionResults {
+		compressionRate, err := parseCompressionRate(compressionResult.OriginalNotional, compressionResult.Notional)
+		if err != nil {
+			return fmt.Errorf("unable to parse book level compression report due to: %s", err.Error())
+		}
+		rows[i] = []interface{}{
+			compressionResult.Party,
+			compressionResult.Book,
+			compressionResult.Currency,
+			compressionResult.MaturityDate,
+			compressionResult.AdjustedMaturityDate,
+			compressionResult.PayOrReceive,
+			string(compressionResult.CompressionType),
+			numericCellValue(compressionResult.OriginalNotional),
+			numericCellValue(compressionResult.Notional),
+			compressionRate / 100,
+			compressionResult.Warning,
+		}
+	}
+
+	return writer.writeSheet(WORKBOOK_COMPRESSION_REPORT_BOOK_LEVEL_SHEET, headers, rows, map[int]int{
+		7: writer.notionalStyle,
+		8: writer.notionalStyle,
+		9: writer.rateStyle,
+	})
+}
+
+func (writer *workbookWriter) writeDataCheckSheet(dataCheckResults []*DataCheckResult, dataCheckTotal *DataCheckResult) error {
+	if dataCheckTotal != nil {
+		dataCheckResults = append(dataCheckResults[:len(dataCheckResults):len(dataCheckResults)], dataCheckTotal)
+	}
+
+	notionalHeaders := []string{"TotalIn", "TotalOut", "NetOut", "Original_Notional", "Notional"}
+	headers := append(append([]string{"Party"}, notionalHeaders...), "Reduced")
+	rows := make([][]interface{}, len(dataCheckResults))
+	for i, dataCheckResult := range dataCheckResults {
+		rows[i] = []interface{}{
+			dataCheckResult.Party,
+			decimalCellValue(dataCheckResult.TotalIn),
+			decimalCellValue(dataCheckResult.TotalOut),
+			decimalCellValue(dataCheckResult.NetOut),
+			decimalCellValue(dataCheckResult.OriginalNotional),
+			decimalCellValue(dataCheckResult.Notional),
+			dataCheckResult.Reduced,
+		}
+	}
+
+	columnToStyle := make(map[int]int, len(notionalHeaders))
+	for i := range notionalHeaders {
+		columnToStyle[i+1] = writer.notionalStyle
+	}
+	return writer.writeSheet(WORKBOOK_DATA_CHECK_SHEET, headers, rows, columnToStyle)
+}
+
+func (writer *workbookWriter) writeBreakReportSheet(breakReport []*BreakCandidate) error {
+	headers := []string{"Party", "Book", "TradeID", "CCPTradeID", "FileName", "RowNumber", "Rank", "Similarity",
+		"CandidateParty", "CandidateBook", "CandidateTradeID", "CandidateCCPTradeID", "CandidateFileName", "CandidateRowNumber", "Differences"}
+	rows := make([][]interface{}, len(breakReport))
+	for i, breakCandidate := range breakReport {
+		var similarity interface{}
+		if len(breakCandidate.Similarity) > 0 {
+			value, err := parseSimilarity(breakCandidate.Similarity)
+			if err != nil {
+				return fmt.Errorf("unable to parse break report due to: %s", err.Error())
+			}
+			similarity = value / 100
+		}
+		rows[i] = []interface{}{
+			breakCandidate.Party,
+			breakCandidate.Book,
+			breakCandidate.TradeID,
+			breakCandidate.CCPTradeID,
+			breakCandidate.FileName,
+			numericCellValue(breakCandidate.RowNumber),
+			numericCellValue(breakCandidate.Rank),
+			similarity,
+			breakCandidate.CandidateParty,
+			breakCandidate.CandidateBook,
+			breakCandidate.CandidateTradeID,
+			breakCandidate.CandidateCCPTradeID,
+			breakCandidate.CandidateFileName,
+			numericCellValue(breakCandidate.CandidateRowNumber),
+			breakCandidate.Differences,
+		}
+	}
+
+	return writer.writeSheet(WORKBOOK_BREAK_REPORT_SHEET, headers, rows, map[int]int{7: writer.rateStyle})
+}
+
+func (writer *workbookWriter) writeProposalsSheet(party string, proposals []*Proposal) error {
+	attributeNames := GetAttributeNames(proposals)
+	headers := append(append([]string{}, PROPOSAL_COLUMNS...), attributeNames...)
+	rows := make([][]interface{}, len(proposals))
+	for i, proposal := range proposals {
+		rows[i] = []interface{}{
+			proposal.Party,
+			proposal.Book,
+			proposal.TradeID,
+			proposal.PayOrReceive,
+			proposal.Currency,
+			proposal.MaturityDate,
+			proposal.AdjustedMaturityDate,
+			proposal.Cpty,
+			proposal.CCPTradeID,
+			decimalCellValue(proposal.Notional),
+			string(proposal.Action),
+			proposal.Warning,
+		}
+		for _, attribute := range attributeNames {
+			rows[i] = append(rows[i], proposal.Attributes[attribute])
+		}
+	}
+
+	return writer.writeSheet(writer.getProposalsSheetName(party), headers, rows, map[int]int{9: writer.notionalStyle})
+}
+
+func (writer *workbookWriter) writeSheet(sheet string, headers []string, rows [][]interface{}, columnToStyle map[int]int) error {
+	if len(writer.sheetNames) == 0 {
+		writer.file.SetSheetName(writer.file.GetSheetName(0), sheet)
+	} else {
+		writer.file.NewSheet(sheet)
+	}
+	writer.sheetNames[strings.ToLower(sheet)] = true
+
+	if err := writer.file.SetSheetRow(sheet, "A1", &headers); err != nil {
+		return err
+	}
+	lastHeaderCell, err := excelize.CoordinatesToCellName(len(headers), 1)
+	if err != nil {
+		return err
+	}
+	if err := writer.file.SetCellStyle(sheet, "A1", lastHeaderCell, writer.headerStyle); err != nil {
+		return err
+	}
+
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+		if err := writer.file.SetSheetRow(sheet, cell, &row); err != nil {
+			return err
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+	for column, style := range columnToStyle {
+		firstCell, err := excelize.CoordinatesToCellName(column+1, 2)
+		if err != nil {
+			return err
+		}
+		lastCell, err := excelize.CoordinatesToCellName(column+1, len(rows)+1)
+		if err != nil {
+			return err
+		}
+		if err := writer.file.SetCellStyle(sheet, firstCell, lastCell, style); err != nil {
+			return err
+		}
+		if style == writer.notionalStyle {
+			if err := writer.setDecimalNotionalStyle(sheet, column, rows); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (writer *workbookWriter) setDecimalNotionalStyle(sheet string, column int, rows [][]interface{}) error {
+	for i, row := range rows {
+		if _, ok := row[column].(float64); !ok {
+			continue
+		}
+		cell, err := excelize.CoordinatesToCellName(column+1, i+2)
+		if err != nil {
+			return err
+		}
+		if err := writer.file.SetCellStyle(sheet, cell, cell, writer.decimalNotionalStyle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getProposalsSheetName returns a sheet name that Excel accepts: at most 31 characters, none of : \ / ? * [ ] and unique ignoring case
+func (writer *workbookWriter) getProposalsSheetName(party string) string {
+	name := []rune(WORKBOOK_PROPOSALS_SHEET_PREFIX + invalidSheetNameChars.Replace(party))
+	if len(name) > WORKBOOK_MAX_SHEET_NAME_LENGTH {
+		name = name[:WORKBOOK_MAX_SHEET_NAME_LENGTH]
+	}
+
+	sheet := string(name)
+	for i := 2; writer.sheetNames[strings.ToLower(sheet)]; i++ {
+		suffix := []rune(fmt.Sprintf(" (%d)", i))
+		if len(name)+len(suffix) > WORKBOOK_MAX_SHEET_NAME_LENGTH {
+			sheet = string(name[:WORKBOOK_MAX_SHEET_NAME_LENGTH-len(suffix)]) + string(suffix)
+		} else {
+			sheet = string(name) + string(suffix)
+		}
+	}
+	return sheet
+}
+
+func numericCellValue(value string) interface{} {
+	number, err := toolkit.ParseDecimal(value)
+	if err != nil {
+		return value
+	}
+	return decimalCellValue(number)
+}
+
+// decimalCellValue returns a whole number as an integer, Excel stores any other number as a float64
+func decimalCellValue(value toolkit.Decimal) interface{} {
+	number, err := strconv.ParseInt(value.String(), 10, 64)
+	if err == nil {
+		return number
+	}
+	float, _ := strconv.ParseFloat(value.String(), 64)
+	return float
+}
+
+func (store *RunStore) GetRunWorkbook(c *gin.Context) {
+	resp, ok := store.loadRequestedRun(c)
+	if !ok {
+		return
+	}
+
+	result, err := ParseRunResult(resp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, api.CompressTradesResp{
+			RequestID: resp.RequestID,
+			Error:     fmt.Sprintf("Error in ParseRunResult due to: %s", err.Error()),
+		})
+		return
+	}
+
+	file, err := newRunWorkbook(result)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, api.CompressTradesResp{
+			RequestID: resp.RequestID,
+			Error:     fmt.Sprintf("Error in newRunWorkbook due to: %s", err.Error()),
+		})
+		return
+	}
+
+	streamAttachment(c, resp.RequestID, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		fmt.Sprintf("%s.xlsx", resp.RequestID), "WriteRunWorkbook", func(w io.Writer) error {
+			return file.Write(w)
+		})
+}
@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"github.com/xuri/excelize/v2"
+	"github.com/zytan787/code-to-connect-2021/internal/toolkit"
+	"testing"
+)
+
+func newTestDataCheckResult(t *testing.T, party string, notional string) *DataCheckResult {
+	t.Helper()
+
+	value, err := toolkit.ParseDecimal(notional)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &DataCheckResult{
+		Party:            party,
+		TotalIn:          value,
+		TotalOut:         value,
+		NetOut:           value,
+		OriginalNotional: value,
+		Notional:         value,
+		Reduced:          true,
+	}
+}
+
+func TestWriteDataCheckSheet(t *testing.T) {
+	result := &RunResult{
+		RequestID:        "run",
+		PartyToProposals: make(map[string][]*Proposal),
+		DataCheckResults: []*DataCheckResult{newTestDataCheckResult(t, "A", "1000.50")},
+		DataCheckTotal:   newTestDataCheckResult(t, "Total", "2000"),
+	}
+
+	file, err := newRunWorkbook(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the notional columns B to F of the decimal row 2 and the whole number row 3
+	rowToStyle := make(map[int]int)
+	for row := 2; row <= 3; row++ {
+		for column := 2; column <= 6; column++ {
+			cell, err := excelize.CoordinatesToCellName(column, row)
+			if err != nil {
+				t.Fatal(err)
+			}
+			style, err := file.GetCellStyle(WORKBOOK_DATA_CHECK_SHEET, cell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if style == 0 {
+				t.Errorf("expected cell %s to have a notional style", cell)
+			}
+			if column == 2 {
+				rowToStyle[row] = style
+			} else if style != rowToStyle[row] {
+				t.Errorf("expected cell %s to have the style %d of the other notionals, got %d", cell, rowToStyle[row], style)
+			}
+		}
+	}
+	if rowToStyle[2] == rowToStyle[3] {
+		t.Errorf("expected the decimal notionals to have another style than the whole notionals")
+	}
+}
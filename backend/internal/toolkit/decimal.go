@@ -0,0 +1,217 @@
+package toolkit
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// a number with optional thousands separators and fraction, like 1000000, 1,000,000 or 1000000.50
+var decimalPattern = regexp.MustCompile(`^([+-]?)(\d{1,3}(?:,\d{3})+|\d+)(?:\.(\d*))?$`)
+
+var powersOfTen = []int64{
+	1, 10, 100, 1000, 10000, 100000, 1000000, 10000000, 100000000, 1000000000,
+	10000000000, 100000000000, 1000000000000, 10000000000000, 100000000000000,
+	1000000000000000, 10000000000000000, 100000000000000000, 1000000000000000000,
+}
+
+// Decimal is an exact fixed-point number equal to coefficient / 10^scale, notionals are kept as Decimal so that
+// they never go through float64. The zero value is 0. The coefficient stays within ±math.MaxInt64, so Add and Sub
+// return an error instead of wrapping around, and Neg and Abs never overflow.
+type Decimal struct {
+	coefficient int64
+	scale       int
+}
+
+func NewDecimal(value int64) Decimal {
+	return Decimal{coefficient: value}
+}
+
+// ParseDecimal parses a number like 1000000, 1,000,000 or 1000000.50 keeping the scale it is written with
+func ParseDecimal(value string) (Decimal, error) {
+	match := decimalPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return Decimal{}, fmt.Errorf("%s is not a valid number", value)
+	}
+
+	digits := strings.ReplaceAll(match[2], ",", "") + match[3]
+	if len(match[3]) >= len(powersOfTen) {
+		return Decimal{}, fmt.Errorf("%s has too many decimal places", value)
+	}
+	coefficient, err := strconv.ParseInt(match[1]+digits, 10, 64)
+	if err != nil || coefficient == math.MinInt64 {
+		return Decimal{}, fmt.Errorf("%s is out of range", value)
+	}
+
+	return Decimal{coefficient: coefficient, scale: len(match[3])}, nil
+}
+
+// Round rounds half away from zero to at most scale decimal places
+func (d Decimal) Round(scale int) Decimal {
+	if scale < 0 || d.scale <= scale {
+		return d
+	}
+
+	divisor := powersOfTen[d.scale-scale]
+	quotient, remainder := d.coefficient/divisor, d.coefficient%divisor
+	if remainder < 0 {
+		remainder = -remainder
+	}
+	if remainder*2 >= divisor {
+		if d.coefficient < 0 {
+			quotient--
+		} else {
+			quotient++
+		}
+	}
+	return Decimal{coefficient: quotient, scale: scale}
+}
+
+// Rescale returns d with exactly scale decimal places, padding it with zeros or rounding it half away from zero,
+// so that equal values are written the same way
+func (d Decimal) Rescale(scale int) (Decimal, error) {
+	if scale < 0 || scale >= len(powersOfTen) {
+		return Decimal{}, fmt.Errorf("%d decimal places is out of range", scale)
+	}
+	if d.scale >= scale {
+		return d.Round(scale), nil
+	}
+
+	coefficient, ok := multiplyByPowerOfTen(d.coefficient, scale-d.scale)
+	if !ok {
+		return Decimal{}, fmt.Errorf("%s with %d decimal places is out of range", d, scale)
+	}
+	return Decimal{coefficient: coefficient, scale: scale}, nil
+}
+
+func (d Decimal) Add(other Decimal) (Decimal, error) {
+	aligned, alignedOther, ok := alignScales(d, other)
+	if !ok || !canAdd(aligned.coefficient, alignedOther.coefficient) {
+		return Decimal{}, fmt.Errorf("%s + %s is out of range", d, other)
+	}
+	return Decimal{coefficient: aligned.coefficient + alignedOther.coefficient, scale: aligned.scale}, nil
+}
+
+func (d Decimal) Sub(other Decimal) (Decimal, error) {
+	aligned, alignedOther, ok := alignScales(d, other)
+	if !ok || !canAdd(aligned.coefficient, -alignedOther.coefficient) {
+		return Decimal{}, fmt.Errorf("%s - %s is out of range", d, other)
+	}
+	return Decimal{coefficient: aligned.coefficient - alignedOther.coefficient, scale: aligned.scale}, nil
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{coefficient: -d.coefficient, scale: d.scale}
+}
+
+func (d Decimal) Abs() Decimal {
+	if d.coefficient < 0 {
+		return d.Neg()
+	}
+	return d
+}
+
+// Cmp returns -1, 0 or 1 when d is less than, equal to or greater than other, whatever their scales
+func (d Decimal) Cmp(other Decimal) int {
+	d, other, ok := alignScales(d, other)
+	if !ok {
+		return d.Rat().Cmp(other.Rat())
+	}
+	if d.coefficient < other.coefficient {
+		return -1
+	}
+	if d.coefficient > other.coefficient {
+		return 1
+	}
+	return 0
+}
+
+func (d Decimal) Sign() int {
+	if d.coefficient < 0 {
+		return -1
+	}
+	if d.coefficient > 0 {
+		return 1
+	}
+	return 0
+}
+
+func (d Decimal) IsZero() bool {
+	return d.coefficient == 0
+}
+
+// Rat returns the exact value of d as a fraction
+func (d Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(d.coefficient), big.NewInt(powersOfTen[d.scale]))
+}
+
+func (d Decimal) String() string {
+	digits := strconv.FormatInt(d.coefficient, 10)
+	sign := ""
+	if d.coefficient < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if d.scale == 0 {
+		return sign + digits
+	}
+
+	if len(digits) <= d.scale {
+		digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+}
+
+func (d Decimal) MarshalCSV() (string, error) {
+	return d.String(), nil
+}
+
+func (d *Decimal) UnmarshalCSV(value string) error {
+	parsed, err := ParseDecimal(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// alignScales rescales the decimal with the smaller scale to the scale of the other one,
+// it returns false and the decimals unchanged when the rescaled coefficient is out of range
+func alignScales(d1 Decimal, d2 Decimal) (Decimal, Decimal, bool) {
+	if d1.scale < d2.scale {
+		coefficient, ok := multiplyByPowerOfTen(d1.coefficient, d2.scale-d1.scale)
+		if !ok {
+			return d1, d2, false
+		}
+		return Decimal{coefficient: coefficient, scale: d2.scale}, d2, true
+	}
+	if d2.scale < d1.scale {
+		coefficient, ok := multiplyByPowerOfTen(d2.coefficient, d1.scale-d2.scale)
+		if !ok {
+			return d1, d2, false
+		}
+		return d1, Decimal{coefficient: coefficient, scale: d1.scale}, true
+	}
+	return d1, d2, true
+}
+
+func multiplyByPowerOfTen(value int64, exponent int) (int64, bool) {
+	if exponent >= len(powersOfTen) {
+		return 0, value == 0
+	}
+	power := powersOfTen[exponent]
+	if value > math.MaxInt64/power || value < -math.MaxInt64/power {
+		return 0, false
+	}
+	return value * power, true
+}
+
+// canAdd reports whether a + b stays within ±math.MaxInt64, given that a and b do
+func canAdd(a int64, b int64) bool {
+	if b > 0 {
+		return a <= math.MaxInt64-b
+	}
+	return a >= -math.MaxInt64-b
+}
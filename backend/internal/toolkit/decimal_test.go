@@ -0,0 +1,125 @@
+package toolkit
+
+import (
+	"testing"
+)
+
+func mustParseDecimal(t *testing.T, value string) Decimal {
+	t.Helper()
+
+	decimal, err := ParseDecimal(value)
+	if err != nil {
+		t.Fatalf("ParseDecimal(%q) returned %s", value, err.Error())
+	}
+	return decimal
+}
+
+func TestDecimalRound(t *testing.T) {
+	tests := []struct {
+		value    string
+		scale    int
+		expected string
+	}{
+		{"2.5", 0, "3"},
+		{"-2.5", 0, "-3"},
+		{"2.49", 0, "2"},
+		{"-2.49", 0, "-2"},
+		{"1.005", 2, "1.01"},
+		{"-1.005", 2, "-1.01"},
+		{"-1.004", 2, "-1.00"},
+		{"-0.5", 0, "-1"},
+		{"-0.05", 1, "-0.1"},
+		{"-0.04", 1, "0.0"},
+		{"1.5", 2, "1.5"},
+		{"-1.5", -1, "-1.5"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			rounded := mustParseDecimal(t, test.value).Round(test.scale)
+			if rounded.String() != test.expected {
+				t.Errorf("expected %s rounded to %d decimal places to be %s, got %s", test.value, test.scale, test.expected, rounded)
+			}
+		})
+	}
+}
+
+func TestDecimalRescale(t *testing.T) {
+	tests := []struct {
+		value    string
+		scale    int
+		expected string
+		hasError bool
+	}{
+		{"100", 2, "100.00", false},
+		{"100.5", 2, "100.50", false},
+		{"100.505", 2, "100.51", false},
+		{"-100.505", 2, "-100.51", false},
+		{"100.50", 0, "101", false},
+		{"100.00", 2, "100.00", false},
+		{"0", 3, "0.000", false},
+		{"100", -1, "", true},
+		{"100", 19, "", true},
+		{"9223372036854775807", 1, "", true},
+		{"-922337203685477580", 2, "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			rescaled, err := mustParseDecimal(t, test.value).Rescale(test.scale)
+			if test.hasError {
+				if err == nil {
+					t.Errorf("expected an error rescaling %s to %d decimal places, got %s", test.value, test.scale, rescaled)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error rescaling %s to %d decimal places, got %s", test.value, test.scale, err.Error())
+			}
+			if rescaled.String() != test.expected {
+				t.Errorf("expected %s rescaled to %d decimal places to be %s, got %s", test.value, test.scale, test.expected, rescaled)
+			}
+		})
+	}
+}
+
+func TestDecimalAddAndSub(t *testing.T) {
+	tests := []struct {
+		name            string
+		value           string
+		other           string
+		sum             string
+		difference      string
+		sumError        bool
+		differenceError bool
+	}{
+		{"different scales", "1.5", "0.25", "1.75", "1.25", false, false},
+		{"negative values", "-1.5", "-0.25", "-1.75", "-1.25", false, false},
+		{"largest value", "9223372036854775806", "1", "9223372036854775807", "9223372036854775805", false, false},
+		{"above the largest value", "9223372036854775807", "1", "", "9223372036854775806", true, false},
+		{"below the smallest value", "-9223372036854775807", "1", "-9223372036854775806", "", false, true},
+		{"subtracting the smallest value", "1", "-9223372036854775807", "-9223372036854775806", "", false, true},
+		{"scale out of range", "9223372036854775807", "0.1", "", "", true, true},
+		{"zero with a large scale", "0", "0.000000000000000001", "0.000000000000000001", "-0.000000000000000001", false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, other := mustParseDecimal(t, test.value), mustParseDecimal(t, test.other)
+
+			sum, err := value.Add(other)
+			if test.sumError != (err != nil) {
+				t.Errorf("expected error %t adding %s and %s, got %v", test.sumError, test.value, test.other, err)
+			} else if err == nil && sum.String() != test.sum {
+				t.Errorf("expected %s + %s to be %s, got %s", test.value, test.other, test.sum, sum)
+			}
+
+			difference, err := value.Sub(other)
+			if test.differenceError != (err != nil) {
+				t.Errorf("expected error %t subtracting %s from %s, got %v", test.differenceError, test.other, test.value, err)
+			} else if err == nil && difference.String() != test.difference {
+				t.Errorf("expected %s - %s to be %s, got %s", test.value, test.other, test.difference, difference)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package toolkit
+
+// EditDistance returns the Levenshtein distance between two strings, the number of single character insertions,
+// deletions and substitutions turning one into the other
+func EditDistance(a string, b string) int {
+	runesA, runesB := []rune(a), []rune(b)
+
+	previous := make([]int, len(runesB)+1)
+	current := make([]int, len(runesB)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(runesA); i++ {
+		current[0] = i
+		for j := 1; j <= len(runesB); j++ {
+			substitution := previous[j-1]
+			if runesA[i-1] != runesB[j-1] {
+				substitution++
+			}
+			current[j] = minInt(substitution, previous[j]+1, current[j-1]+1)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(runesB)]
+}
+
+func minInt(values ...int) int {
+	result := values[0]
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+	return result
+}
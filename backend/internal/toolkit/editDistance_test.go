@@ -0,0 +1,35 @@
+package toolkit
+
+import (
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{"", "", 0},
+		{"CCP1", "", 4},
+		{"", "CCP1", 4},
+		{"CCP1", "CCP1", 0},
+		{"CCP1", "CCP2", 1},
+		{"CCP1", "CCP12", 1},
+		{"CCP12", "CCP1", 1},
+		{"CCP12", "CCP21", 2},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"CCP1", "ccp1", 3},
+		{"CCPé1", "CCPe1", 1},
+		{"日本", "日本語", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.a+"/"+test.b, func(t *testing.T) {
+			if distance := EditDistance(test.a, test.b); distance != test.expected {
+				t.Errorf("expected the edit distance between %q and %q to be %d, got %d", test.a, test.b, test.expected, distance)
+			}
+		})
+	}
+}
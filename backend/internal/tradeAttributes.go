@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"github.com/gocarina/gocsv"
+	"sort"
+	"strings"
+)
+
+// AttributeRule decides the attributes of the trades added by the proposals
+type AttributeRule string
+
+const (
+	// copy the attributes of the cancelled trade with the largest notional of the same party, currency and maturity date
+	ATTRIBUTE_RULE_LARGEST_CANCELLED AttributeRule = "largest_cancelled"
+	// keep the attributes that have the same value on every cancelled trade of the same party, currency and maturity date
+	ATTRIBUTE_RULE_COMMON AttributeRule = "common"
+	// leave the attributes of the added trades empty
+	ATTRIBUTE_RULE_NONE AttributeRule = "none"
+)
+
+const DEFAULT_ATTRIBUTE_RULE = ATTRIBUTE_RULE_LARGEST_CANCELLED
+
+var PROPOSAL_COLUMNS = []string{"Party", "Book", "TradeID", "PAY/RECEIVE", "Currency", "MaturityDate", "AdjustedMaturityDate", "Cpty", "CCPTradeID", "Notional", "Action", "Warning"}
+
+func ParseAttributeRule(rule string) (AttributeRule, error) {
+	switch AttributeRule(strings.ToLower(strings.TrimSpace(rule))) {
+	case "":
+		return DEFAULT_ATTRIBUTE_RULE, nil
+	case ATTRIBUTE_RULE_LARGEST_CANCELLED:
+		return ATTRIBUTE_RULE_LARGEST_CANCELLED, nil
+	case ATTRIBUTE_RULE_COMMON:
+		return ATTRIBUTE_RULE_COMMON, nil
+	case ATTRIBUTE_RULE_NONE:
+		return ATTRIBUTE_RULE_NONE, nil
+	}
+	return "", fmt.Errorf("attribute_rule %s is neither '%s', '%s' or '%s'", rule,
+		ATTRIBUTE_RULE_LARGEST_CANCELLED, ATTRIBUTE_RULE_COMMON, ATTRIBUTE_RULE_NONE)
+}
+
+func isProposalColumn(name string) bool {
+	for _, column := range PROPOSAL_COLUMNS {
+		if strings.EqualFold(column, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// fillNewTradeAttributes sets the attributes of every ADD proposal from the CXL proposals of its key
+func (eventGenerator *EventGenerator) fillNewTradeAttributes(rule AttributeRule) {
+	if rule == ATTRIBUTE_RULE_NONE {
+		return
+	}
+
+	for _, proposals := range eventGenerator.KeyToProposals {
+		cancelledProposals := filterProposalsByActionType(proposals, CANCEL)
+		if len(cancelledProposals) == 0 {
+			continue
+		}
+
+		var attributes map[string]string
+		if rule == ATTRIBUTE_RULE_COMMON {
+			attributes = getCommonAttributes(cancelledProposals)
+		} else {
+			attributes = getLargestProposal(cancelledProposals).Attributes
+		}
+
+		for _, proposal := range proposals {
+			if proposal.Action == ADD {
+				proposal.Attributes = attributes
+			}
+		}
+	}
+}
+
+// getLargestProposal returns the proposal with the largest notional, the smallest trade ID wins a tie
+func getLargestProposal(proposals []*Proposal) *Proposal {
+	largest := proposals[0]
+	for _, proposal := range proposals[1:] {
+		compare := proposal.Notional.Cmp(largest.Notional)
+		if compare > 0 || (compare == 0 && proposal.TradeID < largest.TradeID) {
+			largest = proposal
+		}
+	}
+	return largest
+}
+
+func getCommonAttributes(proposals []*Proposal) map[string]string {
+	if len(proposals[0].Attributes) == 0 {
+		return nil
+	}
+
+	attributes := make(map[string]string, len(proposals[0].Attributes))
+	for attribute, value := range proposals[0].Attributes {
+		common := true
+		for _, proposal := range proposals[1:] {
+			if otherValue, ok := proposal.Attributes[attribute]; !ok || otherValue != value {
+				common = false
+				break
+			}
+		}
+		if common {
+			attributes[attribute] = value
+		}
+	}
+	return attributes
+}
+
+// GetAttributeNames returns the sorted names of the attributes of the proposals
+func GetAttributeNames(proposals []*Proposal) []string {
+	nameSet := make(map[string]bool)
+	for _, proposal := range proposals {
+		for attribute := range proposal.Attributes {
+			nameSet[attribute] = true
+		}
+	}
+
+	names := make([]string, 0, len(nameSet))
+	for name := range nameSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MarshalProposalsCSV writes the proposals like gocsv does, followed by one column per attribute
+func MarshalProposalsCSV(proposals []*Proposal) ([]byte, error) {
+	attributeNames := GetAttributeNames(proposals)
+	if len(attributeNames) == 0 {
+		return gocsv.MarshalBytes(proposals)
+	}
+
+	var buffer bytes.Buffer
+	csvWriter := csv.NewWriter(&buffer)
+	if err := csvWriter.Write(append(append([]string{}, PROPOSAL_COLUMNS...), attributeNames...)); err != nil {
+		return nil, err
+	}
+
+	record := make([]string, len(PROPOSAL_COLUMNS)+len(attributeNames))
+	for _, proposal := range proposals {
+		copy(record, []string{
+			proposal.Party,
+			proposal.Book,
+			proposal.TradeID,
+			proposal.PayOrReceive,
+			proposal.Currency,
+			proposal.MaturityDate,
+			proposal.AdjustedMaturityDate,
+			proposal.Cpty,
+			proposal.CCPTradeID,
+			proposal.Notional.String(),
+			string(proposal.Action),
+			proposal.Warning,
+		})
+		for i, attribute := range attributeNames {
+			record[len(PROPOSAL_COLUMNS)+i] = proposal.Attributes[attribute]
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	csvWriter.Flush()
+	return buffer.Bytes(), csvWriter.Error()
+}
+
+// UnmarshalProposalsCSV reads proposals written by MarshalProposalsCSV, the columns after Warning become attributes
+func UnmarshalProposalsCSV(content []byte) ([]*Proposal, error) {
+	proposals := make([]*Proposal, 0)
+	if len(content) == 0 {
+		return proposals, nil
+	}
+	if err := gocsv.UnmarshalBytes(content, &proposals); err != nil {
+		return nil, err
+	}
+	// runs stored before maturity dates were adjusted have no AdjustedMaturityDate column
+	for _, proposal := range proposals {
+		if len(proposal.AdjustedMaturityDate) == 0 {
+			proposal.AdjustedMaturityDate = proposal.MaturityDate
+		}
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || len(records)-1 != len(proposals) {
+		return proposals, nil
+	}
+
+	columnToAttribute := make(map[int]string)
+	for column, name := range records[0] {
+		if !isProposalColumn(name) {
+			columnToAttribute[column] = name
+		}
+	}
+	for i, proposal := range proposals {
+		proposal.Attributes = newRawTradeAttributes(columnToAttribute, records[i+1])
+	}
+
+	return proposals, nil
+}
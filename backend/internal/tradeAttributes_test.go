@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"encoding/base64"
+	"github.com/zytan787/code-to-connect-2021/api"
+	"net/http"
+	"testing"
+)
+
+const TEST_INPUT_FILE_WITH_ATTRIBUTES = `Party,Book,TradeID,PAY/RECEIVE,Currency,MaturityDate,Cpty,CCPTradeID,Notional,Desk,Trader
+A,1BKA,A0,R,EUR,2022/12/31,D,CCP0,467749,Rates,Ann
+D,1BKD,D0,P,EUR,2022/12/31,A,CCP0,467749,Swaps,Dan
+A,1BKA,A1,P,EUR,2022/12/31,D,CCP1,100000,Rates,Bob
+D,1BKD,D1,R,EUR,2022/12/31,A,CCP1,100000,Swaps,Dan
+`
+
+func TestNewTradeAttributes(t *testing.T) {
+	tradeIDToTrader := map[string]string{"A0": "Ann", "D0": "Dan", "A1": "Bob", "D1": "Dan"}
+	tests := []struct {
+		attributeRule string
+		expected      map[string]map[string]string
+	}{
+		{"largest_cancelled", map[string]map[string]string{
+			"A": {"Desk": "Rates", "Trader": "Ann"},
+			"D": {"Desk": "Swaps", "Trader": "Dan"},
+		}},
+		{"common", map[string]map[string]string{
+			"A": {"Desk": "Rates", "Trader": ""},
+			"D": {"Desk": "Swaps", "Trader": "Dan"},
+		}},
+		{"none", map[string]map[string]string{
+			"A": {"Desk": "", "Trader": ""},
+			"D": {"Desk": "", "Trader": ""},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.attributeRule, func(t *testing.T) {
+			statusCode, resp := runTestRequest(t, nil, api.CompressTradesReq{
+				RequestID:     test.attributeRule,
+				AttributeRule: test.attributeRule,
+				InputFiles: []api.File{{
+					FileName:    "input.csv",
+					FileContent: base64.StdEncoding.EncodeToString([]byte(TEST_INPUT_FILE_WITH_ATTRIBUTES)),
+				}},
+			})
+			if statusCode != http.StatusOK {
+				t.Fatalf("expected the run to succeed, got %d: %s", statusCode, resp.Error)
+			}
+
+			for _, partyProposals := range resp.Proposals {
+				content, err := base64.StdEncoding.DecodeString(partyProposals.Proposal)
+				if err != nil {
+					t.Fatal(err)
+				}
+				proposals, err := UnmarshalProposalsCSV(content)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var noOfNewTrades int
+				for _, proposal := range proposals {
+					if proposal.Action == CANCEL {
+						if proposal.Attributes["Trader"] != tradeIDToTrader[proposal.TradeID] {
+							t.Errorf("expected the cancelled trade %s to keep Trader %q, got %v", proposal.TradeID,
+								tradeIDToTrader[proposal.TradeID], proposal.Attributes)
+						}
+						continue
+					}
+					noOfNewTrades++
+					for attribute, value := range test.expected[partyProposals.Party] {
+						if proposal.Attributes[attribute] != value {
+							t.Errorf("expected the new trade of %s to have %s %q, got %v", partyProposals.Party,
+								attribute, value, proposal.Attributes)
+						}
+					}
+				}
+				if noOfNewTrades != 1 {
+					t.Errorf("expected 1 new trade for %s, got %d", partyProposals.Party, noOfNewTrades)
+				}
+
+				// the proposals read back are written out the same
+				rewritten, err := MarshalProposalsCSV(proposals)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(rewritten) != string(content) {
+					t.Errorf("expected the proposals of %s to be written back as\n%s\ngot\n%s", partyProposals.Party,
+						content, rewritten)
+				}
+			}
+		})
+	}
+}
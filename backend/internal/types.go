@@ -1,6 +1,9 @@
 package internal
 
-import "time"
+import (
+	"github.com/zytan787/code-to-connect-2021/internal/toolkit"
+	"time"
+)
 
 const DATE_FORMAT = "2006/01/02"
 const CCPTRADEID_PREFIX = "CCP"
@@ -17,19 +20,31 @@ type RawTrade struct {
 	Cpty         string `csv:"Cpty"`
 	CCPTradeID   string `csv:"CCPTradeID"`
 	Notional     string `csv:"Notional"`
+	FileName     string `csv:"-"`
+	SheetName    string `csv:"-"`
+	RowNumber    int    `csv:"-"`
+	ParseError   string `csv:"-"`
+	// DateFormat is the date format of the file of the trade, the date format of its party is used when it is empty
+	DateFormat DateFormat `csv:"-"`
+	// Attributes holds the values of the extra columns of the input file, like desk or trader, by header
+	Attributes map[string]string `csv:"-"`
 }
 
 type ExcludedTrade struct {
-	Party        string `csv:"Party"`
-	Book         string `csv:"Book"`
-	TradeID      string `csv:"TradeID"`
-	PayOrReceive string `csv:"PAY/RECEIVE"`
-	Currency     string `csv:"Currency"`
-	MaturityDate string `csv:"MaturityDate"`
-	Cpty         string `csv:"Cpty"`
-	CCPTradeID   string `csv:"CCPTradeID"`
-	Notional     string `csv:"Notional"`
-	Error        string `csv:"Error"`
+	Party                string `csv:"Party"`
+	Book                 string `csv:"Book"`
+	TradeID              string `csv:"TradeID"`
+	PayOrReceive         string `csv:"PAY/RECEIVE"`
+	Currency             string `csv:"Currency"`
+	MaturityDate         string `csv:"MaturityDate"`
+	AdjustedMaturityDate string `csv:"AdjustedMaturityDate"`
+	Cpty                 string `csv:"Cpty"`
+	CCPTradeID           string `csv:"CCPTradeID"`
+	Notional             string `csv:"Notional"`
+	Error                string `csv:"Error"`
+	Warning              string `csv:"Warning"`
+	FileName             string `csv:"FileName"`
+	RowNumber            string `csv:"RowNumber"`
 }
 
 type Trade struct {
@@ -39,9 +54,42 @@ type Trade struct {
 	PayOrReceive string
 	Currency     string
 	MaturityDate time.Time
-	Cpty         string
-	CCPTradeID   string
-	Notional     uint64
+	// AdjustedMaturityDate is the MaturityDate moved to a business day by the business day convention,
+	// trades are paired and compressed by it
+	AdjustedMaturityDate time.Time
+	Cpty                 string
+	CCPTradeID           string
+	Notional             toolkit.Decimal
+	FileName             string
+	SheetName            string
+	RowNumber            int
+	Attributes           map[string]string
+	// SubmittedNotional and SubmittedAdjustedMaturityDate keep the values the trade was submitted with, Notional and
+	// AdjustedMaturityDate are replaced by the values of the canonical side when it is paired within tolerance
+	SubmittedNotional             toolkit.Decimal
+	SubmittedAdjustedMaturityDate time.Time
+	// Warning explains why the trade was paired although it differs from its paired trade within tolerance
+	Warning string
+}
+
+// BreakCandidate is a row of the break report, a trade that could not be paired and one of the trades most likely
+// to be its counterpart, or no candidate when no trade is similar enough
+type BreakCandidate struct {
+	Party               string `csv:"Party"`
+	Book                string `csv:"Book"`
+	TradeID             string `csv:"TradeID"`
+	CCPTradeID          string `csv:"CCPTradeID"`
+	FileName            string `csv:"FileName"`
+	RowNumber           string `csv:"RowNumber"`
+	Rank                string `csv:"Rank"`
+	Similarity          string `csv:"Similarity"`
+	CandidateParty      string `csv:"CandidateParty"`
+	CandidateBook       string `csv:"CandidateBook"`
+	CandidateTradeID    string `csv:"CandidateTradeID"`
+	CandidateCCPTradeID string `csv:"CandidateCCPTradeID"`
+	CandidateFileName   string `csv:"CandidateFileName"`
+	CandidateRowNumber  string `csv:"CandidateRowNumber"`
+	Differences         string `csv:"Differences"`
 }
 
 type CompressionType string
@@ -52,39 +100,47 @@ const (
 )
 
 type CompressionResult struct {
-	Party            string          `csv:"Party"`
-	Currency         string          `csv:"Currency"`
-	MaturityDate     string          `csv:"MaturityDate"`
-	PayOrReceive     string          `csv:"PAY/RECEIVE"`
-	CompressionType  CompressionType `csv:"CompressionType"`
-	OriginalNotional string          `csv:"Original_Notional"`
-	Notional         string          `csv:"Notional"`
-	CompressionRate  string          `csv:"CompressionRate"`
+	Party                string          `csv:"Party"`
+	Currency             string          `csv:"Currency"`
+	MaturityDate         string          `csv:"MaturityDate"`
+	AdjustedMaturityDate string          `csv:"AdjustedMaturityDate"`
+	PayOrReceive         string          `csv:"PAY/RECEIVE"`
+	CompressionType      CompressionType `csv:"CompressionType"`
+	OriginalNotional     string          `csv:"Original_Notional"`
+	Notional             string          `csv:"Notional"`
+	CompressionRate      string          `csv:"CompressionRate"`
+	Warning              string          `csv:"Warning"`
 }
 
 type CompressionResultBookLevel struct {
-	Party            string          `csv:"Party"`
-	Book             string          `csv:"Book"`
-	Currency         string          `csv:"Currency"`
-	MaturityDate     string          `csv:"MaturityDate"`
-	PayOrReceive     string          `csv:"PAY/RECEIVE"`
-	CompressionType  CompressionType `csv:"CompressionType"`
-	OriginalNotional string          `csv:"Original_Notional"`
-	Notional         string          `csv:"Notional"`
-	CompressionRate  string          `csv:"CompressionRate"`
+	Party                string          `csv:"Party"`
+	Book                 string          `csv:"Book"`
+	Currency             string          `csv:"Currency"`
+	MaturityDate         string          `csv:"MaturityDate"`
+	AdjustedMaturityDate string          `csv:"AdjustedMaturityDate"`
+	PayOrReceive         string          `csv:"PAY/RECEIVE"`
+	CompressionType      CompressionType `csv:"CompressionType"`
+	OriginalNotional     string          `csv:"Original_Notional"`
+	Notional             string          `csv:"Notional"`
+	CompressionRate      string          `csv:"CompressionRate"`
+	Warning              string          `csv:"Warning"`
 }
 
 type Proposal struct {
-	Party        string     `csv:"Party"`
-	Book         string     `csv:"Book"`
-	TradeID      string     `csv:"TradeID"`
-	PayOrReceive string     `csv:"PAY/RECEIVE"`
-	Currency     string     `csv:"Currency"`
-	MaturityDate string     `csv:"MaturityDate"`
-	Cpty         string     `csv:"Cpty"`
-	CCPTradeID   string     `csv:"CCPTradeID"`
-	Notional     uint64     `csv:"Notional"`
-	Action       ActionType `csv:"Action"`
+	Party                string          `csv:"Party"`
+	Book                 string          `csv:"Book"`
+	TradeID              string          `csv:"TradeID"`
+	PayOrReceive         string          `csv:"PAY/RECEIVE"`
+	Currency             string          `csv:"Currency"`
+	MaturityDate         string          `csv:"MaturityDate"`
+	AdjustedMaturityDate string          `csv:"AdjustedMaturityDate"`
+	Cpty                 string          `csv:"Cpty"`
+	CCPTradeID           string          `csv:"CCPTradeID"`
+	Notional             toolkit.Decimal `csv:"Notional"`
+	Action               ActionType      `csv:"Action"`
+	Warning              string          `csv:"Warning"`
+	// Attributes are written as extra columns after Warning, see MarshalProposalsCSV
+	Attributes map[string]string `csv:"-"`
 }
 
 type ActionType string
@@ -95,11 +151,11 @@ const (
 )
 
 type DataCheckResult struct {
-	Party            string `csv:"Party"`
-	TotalIn          uint64 `csv:"TotalIn"`
-	TotalOut         uint64 `csv:"TotalOut"`
-	NetOut           int    `csv:"NetOut"`
-	OriginalNotional uint64 `csv:"Original_Notional"`
-	Notional         uint64 `csv:"Notional"`
-	Reduced          bool   `csv:"Reduced"`
+	Party            string          `csv:"Party"`
+	TotalIn          toolkit.Decimal `csv:"TotalIn"`
+	TotalOut         toolkit.Decimal `csv:"TotalOut"`
+	NetOut           toolkit.Decimal `csv:"NetOut"`
+	OriginalNotional toolkit.Decimal `csv:"Original_Notional"`
+	Notional         toolkit.Decimal `csv:"Notional"`
+	Reduced          bool            `csv:"Reduced"`
 }
@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const XLSX_EXTENSION = ".xlsx"
+
+// XLSX files are ZIP archives, so they start with the ZIP local file header signature
+var XLSX_MAGIC_BYTES = []byte("PK\x03\x04")
+
+// the serial number of 9999/12/31, the last date Excel supports
+const XLSX_MAX_DATE_SERIAL = 2958465
+
+func isXLSXFile(fileName string, in *bufio.Reader) bool {
+	if strings.EqualFold(filepath.Ext(fileName), XLSX_EXTENSION) {
+		return true
+	}
+	magicBytes, _ := in.Peek(len(XLSX_MAGIC_BYTES))
+	return bytes.Equal(magicBytes, XLSX_MAGIC_BYTES)
+}
+
+func readXLSXRawTrades(in io.Reader, options InputFileOptions, onRawTrade func(rawTrade *RawTrade)) error {
+	file, err := excelize.OpenReader(in)
+	if err != nil {
+		return fmt.Errorf("unable to open workbook due to: %s", err.Error())
+	}
+
+	sheetName := options.SheetName
+	if len(sheetName) == 0 {
+		sheets := file.GetSheetList()
+		if len(sheets) == 0 {
+			return fmt.Errorf("workbook has no sheet")
+		}
+		sheetName = sheets[0]
+	} else if file.GetSheetIndex(sheetName) == -1 {
+		return fmt.Errorf("sheet %s not found, the workbook has sheets %v", sheetName, file.GetSheetList())
+	}
+
+	// the values without their number format, so a date is read as its serial number and a notional like
+	// 1,000,000.00 as 1000000
+	rows, err := file.GetRows(sheetName, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return fmt.Errorf("unable to read sheet %s due to: %s", sheetName, err.Error())
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("sheet %s is empty", sheetName)
+	}
+
+	columnToField, columnToAttribute, err := getRawTradeColumnToField(rows[0], options.ColumnMapping)
+	if err != nil {
+		return err
+	}
+
+	// attributes are kept as shown in Excel, the sheet is only read a second time when it has attribute columns
+	var formattedRows [][]string
+	if len(columnToAttribute) > 0 {
+		if formattedRows, err = file.GetRows(sheetName); err != nil {
+			return fmt.Errorf("unable to read sheet %s due to: %s", sheetName, err.Error())
+		}
+	}
+
+	for i := 1; i < len(rows); i++ {
+		if isEmptyRow(rows[i]) {
+			continue
+		}
+
+		rawTrade := newRawTrade(columnToField, rows[i])
+		if i < len(formattedRows) {
+			rawTrade.Attributes = newRawTradeAttributes(columnToAttribute, formattedRows[i])
+		}
+		rawTrade.MaturityDate = convertExcelDate(rawTrade.MaturityDate)
+		rawTrade.SheetName = sheetName
+		rawTrade.RowNumber = i + 1
+		onRawTrade(rawTrade)
+	}
+
+	return nil
+}
+
+func isEmptyRow(row []string) bool {
+	for _, cell := range row {
+		if len(strings.TrimSpace(cell)) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// convertExcelDate formats a date cell that excelize returns as a serial number, like 45658 for 2025/01/01
+func convertExcelDate(value string) string {
+	serial, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || serial <= 0 || serial > XLSX_MAX_DATE_SERIAL {
+		return value
+	}
+
+	date, err := excelize.ExcelDateToTime(serial, false)
+	if err != nil {
+		return value
+	}
+	return date.Format(DATE_FORMAT)
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"bytes"
+	"github.com/xuri/excelize/v2"
+	"testing"
+	"time"
+)
+
+func TestReadXLSXRawTrades(t *testing.T) {
+	file := excelize.NewFile()
+	sheetName := file.GetSheetName(0)
+	rows := [][]interface{}{
+		{"Party", "Book", "TradeID", "PAY/RECEIVE", "Currency", "MaturityDate", "Cpty", "CCPTradeID", "Notional", "TradeDate"},
+		{"A", "1BKA", "A1", "P", "EUR", time.Date(2025, 4, 3, 0, 0, 0, 0, time.UTC), "D", "CCP1", 1000000.5,
+			time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"A", "1BKA", "A2", "R", "EUR", "2025/04/04", "D", "CCP2", "2,000", "2020/01/03"},
+		{},
+		{"A", "1BKA", "A3", "R", "EUR", 45658, "D", "CCP3", 3000000, ""},
+	}
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		row := row
+		if err = file.SetSheetRow(sheetName, cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notionalFormat := "#,##0.00"
+	notionalStyle, err := file.NewStyle(&excelize.Style{CustomNumFmt: &notionalFormat})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dateFormat := "dd/mm/yyyy"
+	dateStyle, err := file.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = file.SetCellStyle(sheetName, "I2", "I5", notionalStyle); err != nil {
+		t.Fatal(err)
+	}
+	if err = file.SetCellStyle(sheetName, "F2", "F5", dateStyle); err != nil {
+		t.Fatal(err)
+	}
+	if err = file.SetCellStyle(sheetName, "J2", "J2", dateStyle); err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	if err = file.Write(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	var rawTrades []*RawTrade
+	err = readXLSXRawTrades(&buffer, InputFileOptions{}, func(rawTrade *RawTrade) {
+		rawTrades = append(rawTrades, rawTrade)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		tradeID      string
+		maturityDate string
+		notional     string
+		tradeDate    string
+		rowNumber    int
+	}{
+		{"A1", "2025/04/03", "1000000.5", "02/01/2020", 2},
+		{"A2", "2025/04/04", "2,000", "2020/01/03", 3},
+		{"A3", "2025/01/01", "3000000", "", 5},
+	}
+	if len(rawTrades) != len(expected) {
+		t.Fatalf("expected %d trades, got %d", len(expected), len(rawTrades))
+	}
+	for i, rawTrade := range rawTrades {
+		if rawTrade.TradeID != expected[i].tradeID || rawTrade.MaturityDate != expected[i].maturityDate ||
+			rawTrade.Notional != expected[i].notional || rawTrade.Attributes["TradeDate"] != expected[i].tradeDate ||
+			rawTrade.RowNumber != expected[i].rowNumber || rawTrade.SheetName != sheetName {
+			t.Errorf("expected %+v, got %+v", expected[i], rawTrade)
+		}
+	}
+}
@@ -6,30 +6,114 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/zytan787/code-to-connect-2021/internal"
 	"os"
+	"strconv"
 	"time"
 )
 
+const DEFAULT_JOB_WORKERS = 2
+const DEFAULT_JOB_QUEUE_SIZE = 100
+const DEFAULT_RUN_STORE_DIR = "runs"
+
+var runStore *internal.RunStore
+var columnMappingProfiles internal.ColumnMappingProfiles
+var allowedCurrencies map[string]bool
+var holidayCalendars internal.HolidayCalendars
+var maxUploadedWholeFileSize int64
+
 func main() {
 	err := godotenv.Load(".env")
 	if err != nil {
 		panic(err)
 	}
 	router := gin.Default()
+	// a request_id escaped in the URL can contain a '/'
+	router.UseRawPath = true
 
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{os.Getenv("FRONTEND_HOST")},
-		AllowMethods:     []string{"POST"},
+		AllowMethods:     []string{"GET", "POST"},
 		AllowHeaders:     []string{"Origin"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
+	runStoreDir := os.Getenv("RUN_STORE_DIR")
+	if len(runStoreDir) == 0 {
+		runStoreDir = DEFAULT_RUN_STORE_DIR
+	}
+	runStore, err = internal.NewRunStore(runStoreDir)
+	if err != nil {
+		panic(err)
+	}
+
+	columnMappingsFile := os.Getenv("COLUMN_MAPPINGS_FILE")
+	if len(columnMappingsFile) > 0 {
+		columnMappingProfiles, err = internal.LoadColumnMappingProfiles(columnMappingsFile)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	allowedCurrencies, err = internal.ParseAllowedCurrencies(os.Getenv("ALLOWED_CURRENCIES"))
+	if err != nil {
+		panic(err)
+	}
+
+	holidayCalendarsDir := os.Getenv("HOLIDAY_CALENDARS_DIR")
+	if len(holidayCalendarsDir) > 0 {
+		holidayCalendars, err = internal.LoadHolidayCalendars(holidayCalendarsDir)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	maxUploadedWholeFileSize = int64(getEnvAsInt("MAX_UPLOADED_WHOLE_FILE_SIZE", internal.DEFAULT_MAX_UPLOADED_WHOLE_FILE_SIZE))
+
+	jobManager := internal.NewJobManager(
+		getEnvAsInt("JOB_WORKERS", DEFAULT_JOB_WORKERS),
+		getEnvAsInt("JOB_QUEUE_SIZE", DEFAULT_JOB_QUEUE_SIZE),
+		runStore,
+		columnMappingProfiles,
+		allowedCurrencies,
+		holidayCalendars)
+
 	router.POST("/compress_trades", startCompressTrades)
+	router.POST("/compress_trades/upload", startCompressUploadedTrades)
+	router.POST("/jobs", jobManager.SubmitJob)
+	router.GET("/jobs/:id", jobManager.GetJobStatus)
+	router.GET("/jobs/:id/result", jobManager.GetJobResult)
+	router.GET("/runs", runStore.GetRuns)
+	router.GET("/runs/:id", runStore.GetRun)
+	router.GET("/runs/:id/bundle", runStore.GetRunBundle)
+	router.GET("/runs/:id/xlsx", runStore.GetRunWorkbook)
+	router.GET("/runs/:id/fpml/:party", runStore.GetRunProposalsFpML)
 	router.Run()
 }
 
 func startCompressTrades(c *gin.Context) {
 	mainHandler := internal.NewMainHandler()
+	mainHandler.RunStore = runStore
+	mainHandler.ColumnMappingProfiles = columnMappingProfiles
+	mainHandler.AllowedCurrencies = allowedCurrencies
+	mainHandler.HolidayCalendars = holidayCalendars
 	mainHandler.CompressTrades(c)
 }
+
+func startCompressUploadedTrades(c *gin.Context) {
+	mainHandler := internal.NewMainHandler()
+	mainHandler.RunStore = runStore
+	mainHandler.ColumnMappingProfiles = columnMappingProfiles
+	mainHandler.AllowedCurrencies = allowedCurrencies
+	mainHandler.HolidayCalendars = holidayCalendars
+	mainHandler.MaxUploadedWholeFileSize = maxUploadedWholeFileSize
+	mainHandler.CompressUploadedTrades(c)
+}
+
+func getEnvAsInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -14,6 +14,7 @@ export type CompressTradesResp = {
   compression_report_book_level: string;
   proposals: Proposal[];
   data_check: string;
+  break_report: string;
   statistics: Statistic[];
   error?: string;
 };
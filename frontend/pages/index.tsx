@@ -129,6 +129,11 @@ export default function Home() {
         fileContent: Base64.atob(data.data_check),
       });
 
+      outputFiles.push({
+        key: "break_report.csv",
+        fileContent: Base64.atob(data.break_report),
+      });
+
       setOutputFiles(outputFiles);
     } else {
       setOutputFiles(undefined);
@@ -165,6 +170,7 @@ export default function Home() {
       initialCheckedKeys.push("compression_report.csv");
       initialCheckedKeys.push("compression_report_bookLevel.csv");
       initialCheckedKeys.push("data_check.csv");
+      initialCheckedKeys.push("break_report.csv");
 
       setCheckedKeys(initialCheckedKeys);
 
@@ -181,6 +187,7 @@ export default function Home() {
             },
             { title: "proposals", key: "proposals", children: proposals },
             { title: "data_check.csv", key: "data_check.csv" },
+            { title: "break_report.csv", key: "break_report.csv" },
           ],
         },
       ];